@@ -0,0 +1,451 @@
+package pail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/shard"
+)
+
+var ErrBatchCommitted = errors.New("batch already committed")
+
+// pendingShard is a placeholder shard link for entries whose child shard has
+// been created by the batch and has not yet been encoded.
+var pendingShard ipld.Link = cidlink.Link{Cid: cid.Undef}
+
+// batchShard is an in memory, mutable shard. Entries that link to a shard that
+// has been loaded (or created) by the batch have the child stored in children,
+// keyed by entry key. The shard link in the entry is replaced on commit.
+type batchShard struct {
+	prefix   string
+	entries  []shard.Entry
+	children map[string]*batchShard
+	// fanout indicates the entries link to parts (see [shard.WithFanout]).
+	fanout bool
+	// base is the shard this shard was loaded from, nil if it was created by
+	// the batch.
+	base  shard.BlockView
+	dirty bool
+}
+
+func newBatchShard(b shard.BlockView) *batchShard {
+	return &batchShard{
+		prefix:   b.Value().Prefix(),
+		entries:  slices.Clone(b.Value().Entries()),
+		children: map[string]*batchShard{},
+		fanout:   shard.IsFanout(b.Value()),
+		base:     b,
+	}
+}
+
+// Batcher collects many put and delete operations and applies them to an in
+// memory copy of the shards they touch. Shards are only encoded once, when the
+// batch is committed, so the returned diff contains just the blocks that
+// survive. The resulting root is the same as would be obtained by applying
+// the operations one by one with [Put] and [Del], unless the pail has a
+// maximum shard size.
+//
+// Shards that exceed the maximum shard size are split into parts when they are
+// encoded, and are not merged again when entries are deleted, so the shape of
+// such a pail depends on the history of operations. The batch splits shards
+// once, on commit, so a shard that was only too large part way through the
+// batch is not split, where applying the operations one by one would have
+// split it. The pail has the same entries either way.
+type Batcher struct {
+	shards    *shard.Fetcher
+	rshard    shard.RootShard
+	root      *batchShard
+	removals  []shard.BlockView
+	committed bool
+}
+
+// NewBatcher creates a new [Batcher] for the pail with the given root.
+func NewBatcher(ctx context.Context, blocks block.Fetcher, root ipld.Link) (*Batcher, error) {
+	shards := shard.NewFetcher(blocks)
+	rshard, err := shards.GetRoot(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	return &Batcher{
+		shards: shards,
+		rshard: rshard.Value(),
+		root:   newBatchShard(shard.AsBlock(rshard)),
+	}, nil
+}
+
+// Put a value (a CID) for the given key. If the key exists it's value is
+// overwritten.
+func (b *Batcher) Put(ctx context.Context, key string, value ipld.Link) error {
+	if b.committed {
+		return ErrBatchCommitted
+	}
+
+	err := validateKey(b.rshard, key)
+	if err != nil {
+		return err
+	}
+	err = validateValue(b.rshard, value)
+	if err != nil {
+		return err
+	}
+
+	path, err := b.traverse(ctx, b.root, key)
+	if err != nil {
+		return fmt.Errorf("traversing shard: %w", err)
+	}
+	target := path[len(path)-1]
+	skey := key[len(target.prefix):]
+
+	entry := shard.NewEntry(skey, shard.NewValue(value, nil))
+	var child *batchShard
+
+	for i, e := range target.entries {
+		k := e.Key()
+		v := e.Value()
+
+		// is this just a replace?
+		if k == skey {
+			break
+		}
+
+		// do we need to shard this entry?
+		var shortest string
+		var longest string
+		if len(k) < len(skey) {
+			shortest = k
+			longest = skey
+		} else {
+			shortest = skey
+			longest = k
+		}
+
+		common := shortest[:commonPrefixLen(b.rshard, shortest, longest)]
+		if common == "" {
+			continue
+		}
+
+		child = &batchShard{prefix: target.prefix + common, children: map[string]*batchShard{}, dirty: true}
+
+		// if the existing entry key or new key is equal to the common prefix,
+		// then the existing value / new value needs to persist in the parent
+		// shard. Otherwise they persist in this new shard.
+		if common != skey {
+			child.entries = shard.PutEntry(
+				child.entries,
+				shard.NewEntry(skey[len(common):], shard.NewValue(value, nil)),
+			)
+		}
+		if common != k {
+			child.entries = shard.PutEntry(child.entries, shard.NewEntry(k[len(common):], v))
+			if c, ok := target.children[k]; ok {
+				child.children[k[len(common):]] = c
+			}
+		}
+
+		// create parent shards for each character of the common prefix
+		commonChars := splitKey(b.rshard, common)
+		for i := len(commonChars) - 1; i > 0; i-- {
+			var parentValue shard.Value
+
+			// if the first iteration and the existing entry key is equal to the
+			// common prefix, then existing value needs to persist in this parent
+			if i == len(commonChars)-1 && common == k {
+				if v.Shard() != nil {
+					return errors.New("found a shard link when expecting a value")
+				}
+				parentValue = shard.NewValue(v.Value(), pendingShard)
+			} else if i == len(commonChars)-1 && common == skey {
+				parentValue = shard.NewValue(value, pendingShard)
+			} else {
+				parentValue = shard.NewValue(nil, pendingShard)
+			}
+
+			child = &batchShard{
+				prefix:   target.prefix + strings.Join(commonChars[0:i], ""),
+				entries:  []shard.Entry{shard.NewEntry(commonChars[i], parentValue)},
+				children: map[string]*batchShard{commonChars[i]: child},
+				dirty:    true,
+			}
+		}
+
+		// remove the sharded entry
+		target.entries = slices.Delete(target.entries, i, i+1)
+		delete(target.children, k)
+
+		// create the entry that will be added to target
+		if len(commonChars) == 1 && common == k {
+			entry = shard.NewEntry(commonChars[0], shard.NewValue(v.Value(), pendingShard))
+		} else if len(commonChars) == 1 && common == skey {
+			entry = shard.NewEntry(commonChars[0], shard.NewValue(value, pendingShard))
+		} else {
+			entry = shard.NewEntry(commonChars[0], shard.NewValue(nil, pendingShard))
+		}
+		break
+	}
+
+	target.entries = shard.PutEntry(target.entries, entry)
+	if child != nil {
+		target.children[entry.Key()] = child
+	}
+
+	for _, s := range path {
+		s.dirty = true
+	}
+	return nil
+}
+
+// Del deletes the value for the given key. If the key is not found,
+// [ErrNotFound] is returned as the error value.
+func (b *Batcher) Del(ctx context.Context, key string) error {
+	if b.committed {
+		return ErrBatchCommitted
+	}
+
+	path, err := b.traverse(ctx, b.root, key)
+	if err != nil {
+		return fmt.Errorf("traversing shard: %w", err)
+	}
+	target := path[len(path)-1]
+	skey := key[len(target.prefix):]
+
+	entryidx := slices.IndexFunc(target.entries, func(e shard.Entry) bool {
+		return e.Key() == skey
+	})
+	if entryidx == -1 {
+		return ErrNotFound
+	}
+
+	entry := target.entries[entryidx]
+	// cannot delete a shard (without data)
+	if entry.Value().Value() == nil {
+		return ErrNotFound
+	}
+
+	for _, s := range path {
+		s.dirty = true
+	}
+
+	if entry.Value().Shard() != nil {
+		// remove the value from this link+value
+		target.entries[entryidx] = shard.NewEntry(entry.Key(), shard.NewValue(nil, entry.Value().Shard()))
+		return nil
+	}
+
+	target.entries = slices.Delete(target.entries, entryidx, entryidx+1)
+
+	// if now empty, remove from parent
+	for i := len(path) - 1; i > 0; i-- {
+		child := path[i]
+		if len(child.entries) > 0 {
+			break
+		}
+
+		parent := path[i-1]
+		key, ok := childKey(parent, child)
+		if !ok { // should not happen!
+			return errors.New("did not find child shard in parent")
+		}
+
+		entidx := slices.IndexFunc(parent.entries, func(e shard.Entry) bool {
+			return e.Key() == key && e.Value().Shard() != nil
+		})
+		if entidx == -1 { // should not happen!
+			return errors.New("did not find child shard link in parent")
+		}
+
+		// delete the parent entry, unless it has a value also, then just clear
+		// the shard link.
+		if parent.entries[entidx].Value().Value() != nil {
+			parent.entries[entidx] = shard.NewEntry(key, shard.NewValue(parent.entries[entidx].Value().Value(), nil))
+		} else {
+			parent.entries = slices.Delete(parent.entries, entidx, entidx+1)
+		}
+		delete(parent.children, key)
+
+		// the first part of a fanout shard has an empty key
+		if parent.fanout && entidx == 0 && len(parent.entries) > 0 {
+			first := parent.entries[0].Key()
+			parent.entries[0] = partEntry("", parent.entries[0].Value().Shard())
+			if c, ok := parent.children[first]; ok {
+				delete(parent.children, first)
+				parent.children[""] = c
+			}
+		}
+
+		if child.base != nil {
+			b.removals = append(b.removals, child.base)
+		}
+	}
+
+	return nil
+}
+
+// Commit encodes all the shards modified by the batch and returns the new root
+// along with the blocks that were added and removed. The batcher cannot be
+// used after it has been committed.
+func (b *Batcher) Commit() (ipld.Link, shard.Diff, error) {
+	if b.committed {
+		return nil, shard.Diff{}, ErrBatchCommitted
+	}
+	b.committed = true
+
+	diff := shard.Diff{Removals: b.removals}
+	if !b.root.dirty {
+		return b.root.base.Link(), diff, nil
+	}
+
+	entries, err := b.commitEntries(b.root, &diff)
+	if err != nil {
+		return nil, shard.Diff{}, err
+	}
+	// a fanout root shard without parts is an empty root shard
+	blocks, err := encodeRoot(b.rshard, b.root.fanout && len(entries) > 0, entries)
+	if err != nil {
+		return nil, shard.Diff{}, err
+	}
+
+	// if no change in the root then there is nothing to add or remove
+	root := blocks[len(blocks)-1].Link()
+	if root.String() == b.root.base.Link().String() {
+		return root, diff, nil
+	}
+
+	diff.Additions = append(diff.Additions, blocks...)
+	diff.Removals = append(diff.Removals, b.root.base)
+	return root, newDiff(diff.Additions, diff.Removals), nil
+}
+
+// commit encodes the passed non-root shard, and any modified shards below it,
+// returning the fanout entries that link to the encoded shard, or to its parts
+// if it was split (see [encodeShard]).
+func (b *Batcher) commit(s *batchShard, diff *shard.Diff) ([]shard.Entry, error) {
+	if !s.dirty {
+		return []shard.Entry{partEntry("", s.base.Link())}, nil
+	}
+
+	entries, err := b.commitEntries(s, diff)
+	if err != nil {
+		return nil, err
+	}
+
+	// split the shard if it is now too large
+	links, blocks, err := encodeShard(b.rshard, s.prefix, s.fanout, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	// if no change in the shard then there is nothing to add or remove
+	if s.base != nil && len(links) == 1 && links[0].Value().Shard().String() == s.base.Link().String() {
+		return links, nil
+	}
+
+	diff.Additions = append(diff.Additions, blocks...)
+	if s.base != nil {
+		diff.Removals = append(diff.Removals, s.base)
+	}
+	return links, nil
+}
+
+// commitEntries commits the children of the passed shard, returning its
+// entries with the links to them replaced. The parts of a child that was split
+// take its place in a fanout shard, and are linked from a new fanout shard
+// otherwise.
+func (b *Batcher) commitEntries(s *batchShard, diff *shard.Diff) ([]shard.Entry, error) {
+	entries := make([]shard.Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		c, ok := s.children[e.Key()]
+		if !ok {
+			entries = append(entries, e)
+			continue
+		}
+		links, err := b.commit(c, diff)
+		if err != nil {
+			return nil, err
+		}
+		if s.fanout {
+			links[0] = partEntry(e.Key(), links[0].Value().Shard())
+			entries = append(entries, links...)
+			continue
+		}
+		link, blocks, err := joinParts(b.rshard, c.prefix, links)
+		if err != nil {
+			return nil, err
+		}
+		diff.Additions = append(diff.Additions, blocks...)
+		entries = append(entries, shard.NewEntry(e.Key(), shard.NewValue(e.Value().Value(), link)))
+	}
+	return entries, nil
+}
+
+// childKey returns the key of the entry of the parent that links to the child.
+func childKey(parent *batchShard, child *batchShard) (string, bool) {
+	for k, c := range parent.children {
+		if c == child {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// traverse from the passed shard to the target shard using the passed key,
+// loading shards into the batch as required. All traversed shards are
+// returned, starting with the passed shard and ending with the target.
+//
+// Fanout shards are traversed to the part the key belongs to, so the target is
+// never a fanout shard.
+func (b *Batcher) traverse(ctx context.Context, s *batchShard, key string) ([]*batchShard, error) {
+	if s.fanout {
+		e := s.entries[route(s.entries, key)]
+		child, err := b.child(ctx, s, e)
+		if err != nil {
+			return nil, err
+		}
+		path, err := b.traverse(ctx, child, key)
+		if err != nil {
+			return nil, err
+		}
+		return append([]*batchShard{s}, path...), nil
+	}
+	for _, e := range s.entries {
+		k := e.Key()
+		v := e.Value()
+		if key == k {
+			break
+		}
+		if strings.HasPrefix(key, k) && v.Shard() != nil {
+			child, err := b.child(ctx, s, e)
+			if err != nil {
+				return nil, err
+			}
+			path, err := b.traverse(ctx, child, key[len(k):])
+			if err != nil {
+				return nil, err
+			}
+			return append([]*batchShard{s}, path...), nil
+		}
+	}
+	return []*batchShard{s}, nil
+}
+
+// child returns the shard linked to from the entry of the passed shard,
+// loading it into the batch if required.
+func (b *Batcher) child(ctx context.Context, s *batchShard, e shard.Entry) (*batchShard, error) {
+	child, ok := s.children[e.Key()]
+	if ok {
+		return child, nil
+	}
+	blk, err := b.shards.Get(ctx, e.Value().Shard())
+	if err != nil {
+		return nil, fmt.Errorf("getting shard %s: %w", e.Value().Shard().String(), err)
+	}
+	child = newBatchShard(blk)
+	s.children[e.Key()] = child
+	return child, nil
+}
@@ -0,0 +1,324 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("puts produce same root as sequential puts", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := rb0.Link()
+		objects := []object{
+			{"aaaa", testutil.RandomLink(t)},
+			{"aabb", testutil.RandomLink(t)},
+			{"aa", testutil.RandomLink(t)},
+			{"aaaaA", testutil.RandomLink(t)},
+			{"abbb", testutil.RandomLink(t)},
+			{"a", testutil.RandomLink(t)},
+			{"bbbb", testutil.RandomLink(t)},
+			{"c", testutil.RandomLink(t)},
+			{"cats/tabby", testutil.RandomLink(t)},
+			{"cats/ginger", testutil.RandomLink(t)},
+		}
+		// sequential operations are applied to a separate blockstore
+		sbs := testutil.NewBlockstore()
+		err = sbs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		expect := putAll(t, sbs, r0, objects)
+
+		batch, err := NewBatcher(ctx, bs, r0)
+		require.NoError(t, err)
+		for _, o := range objects {
+			err := batch.Put(ctx, o.key, o.value)
+			require.NoError(t, err)
+		}
+		r1, diff, err := batch.Commit()
+		require.NoError(t, err)
+		require.Equal(t, expect.String(), r1.String())
+
+		require.Len(t, diff.Removals, 1)
+		require.Equal(t, r0.String(), diff.Removals[0].Link().String())
+		require.Equal(t, r1.String(), diff.Additions[len(diff.Additions)-1].Link().String())
+
+		testutil.ApplyDiff(t, diff, bs)
+		require.ElementsMatch(t, reachable(t, bs, r1), links(diff.Additions))
+	})
+
+	t.Run("puts and deletes produce same root as sequential operations", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		var objects []object
+		for i := range 100 {
+			objects = append(objects, object{fmt.Sprintf("key/%d", i), testutil.RandomLink(t)})
+		}
+		r0 := putAll(t, bs, rb0.Link(), objects)
+
+		// sequential operations are applied to a copy of the blockstore
+		sbs := testutil.NewBlockstore()
+		copyPail(t, bs, sbs, r0)
+
+		batch, err := NewBatcher(ctx, bs, r0)
+		require.NoError(t, err)
+
+		expect := r0
+		for i := range 100 {
+			key := fmt.Sprintf("key/%d", i)
+			if i%3 == 0 {
+				r, diff, err := Del(ctx, sbs, expect, key)
+				require.NoError(t, err)
+				testutil.ApplyDiff(t, diff, sbs)
+				expect = r
+
+				err = batch.Del(ctx, key)
+				require.NoError(t, err)
+			} else if i%3 == 1 {
+				value := testutil.RandomLink(t)
+				r, diff, err := Put(ctx, sbs, expect, key+"/sub", value)
+				require.NoError(t, err)
+				testutil.ApplyDiff(t, diff, sbs)
+				expect = r
+
+				err = batch.Put(ctx, key+"/sub", value)
+				require.NoError(t, err)
+			}
+		}
+
+		r1, diff, err := batch.Commit()
+		require.NoError(t, err)
+		require.Equal(t, expect.String(), r1.String())
+
+		testutil.ApplyDiff(t, diff, bs)
+		require.ElementsMatch(t, reachable(t, sbs, expect), reachable(t, bs, r1))
+	})
+
+	t.Run("diff contains only surviving blocks", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := putAll(t, bs, rb0.Link(), []object{
+			{"aaaa", testutil.RandomLink(t)},
+			{"bbbb", testutil.RandomLink(t)},
+			{"bbcc", testutil.RandomLink(t)},
+		})
+		before := reachable(t, bs, r0)
+
+		batch, err := NewBatcher(ctx, bs, r0)
+		require.NoError(t, err)
+
+		err = batch.Put(ctx, "aabb", testutil.RandomLink(t))
+		require.NoError(t, err)
+		err = batch.Put(ctx, "aacc", testutil.RandomLink(t))
+		require.NoError(t, err)
+		err = batch.Del(ctx, "aaaa")
+		require.NoError(t, err)
+
+		r1, diff, err := batch.Commit()
+		require.NoError(t, err)
+
+		testutil.ApplyDiff(t, diff, bs)
+		after := reachable(t, bs, r1)
+
+		for _, l := range links(diff.Additions) {
+			require.Contains(t, after, l)
+			require.NotContains(t, before, l)
+		}
+		for _, l := range links(diff.Removals) {
+			require.Contains(t, before, l)
+			require.NotContains(t, after, l)
+		}
+		// the "b" shard was not modified
+		for _, l := range before {
+			if !contains(links(diff.Removals), l) {
+				require.Contains(t, after, l)
+			}
+		}
+	})
+
+	t.Run("max shard size", func(t *testing.T) {
+		rb0, err := New(shard.WithMaxShardSize(1000))
+		require.NoError(t, err)
+
+		// sequential operations are applied to a separate blockstore
+		sbs := testutil.NewBlockstore()
+		err = sbs.Put(ctx, rb0)
+		require.NoError(t, err)
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		k0 := strings.Repeat("a", 601)
+		k1 := strings.Repeat("b", 501)
+		v0 := testutil.RandomLink(t)
+		expect := putAll(t, sbs, rb0.Link(), []object{{k0, v0}, {k1, testutil.RandomLink(t)}})
+		expect, diff, err := Del(ctx, sbs, expect, k1)
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, sbs)
+
+		batch, err := NewBatcher(ctx, bs, rb0.Link())
+		require.NoError(t, err)
+		err = batch.Put(ctx, k0, v0)
+		require.NoError(t, err)
+		err = batch.Put(ctx, k1, testutil.RandomLink(t))
+		require.NoError(t, err)
+		err = batch.Del(ctx, k1)
+		require.NoError(t, err)
+		r1, diff, err := batch.Commit()
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+
+		// the root was split by the sequential puts, but the batch never
+		// encoded it while it was too large
+		require.Len(t, reachable(t, sbs, expect), 2)
+		require.Len(t, reachable(t, bs, r1), 1)
+		require.NotEqual(t, expect.String(), r1.String())
+
+		require.Equal(t, collectEntries(t, sbs, expect), collectEntries(t, bs, r1))
+		require.Equal(t, []Entry{{k0, v0}}, collectEntries(t, bs, r1))
+		for _, r := range []struct {
+			blocks block.Fetcher
+			root   ipld.Link
+		}{{sbs, expect}, {bs, r1}} {
+			require.LessOrEqual(t, maxShardBytes(t, r.blocks, r.root), 1000)
+			problems, err := Verify(ctx, r.blocks, r.root)
+			require.NoError(t, err)
+			require.Empty(t, problems)
+		}
+	})
+
+	t.Run("commit without changes", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		v0 := testutil.RandomLink(t)
+		r0 := putAll(t, bs, rb0.Link(), []object{{"aaaa", v0}, {"aabb", testutil.RandomLink(t)}})
+
+		batch, err := NewBatcher(ctx, bs, r0)
+		require.NoError(t, err)
+
+		err = batch.Put(ctx, "aaaa", v0)
+		require.NoError(t, err)
+
+		r1, diff, err := batch.Commit()
+		require.NoError(t, err)
+		require.Equal(t, r0.String(), r1.String())
+		require.Len(t, diff.Additions, 0)
+		require.Len(t, diff.Removals, 0)
+	})
+
+	t.Run("del not found", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		batch, err := NewBatcher(ctx, bs, rb0.Link())
+		require.NoError(t, err)
+
+		err = batch.Del(ctx, "test")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("cannot use after commit", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		batch, err := NewBatcher(ctx, bs, rb0.Link())
+		require.NoError(t, err)
+
+		_, _, err = batch.Commit()
+		require.NoError(t, err)
+
+		err = batch.Put(ctx, "test", testutil.RandomLink(t))
+		require.ErrorIs(t, err, ErrBatchCommitted)
+
+		_, _, err = batch.Commit()
+		require.ErrorIs(t, err, ErrBatchCommitted)
+	})
+}
+
+// reachable returns the links of all the shards reachable from the root.
+func reachable(t *testing.T, blocks block.Fetcher, root ipld.Link) []string {
+	ctx := context.Background()
+	shards := shard.NewFetcher(blocks)
+	s, err := shards.Get(ctx, root)
+	require.NoError(t, err)
+
+	links := []string{root.String()}
+	for _, e := range s.Value().Entries() {
+		if e.Value().Shard() != nil {
+			links = append(links, reachable(t, blocks, e.Value().Shard())...)
+		}
+	}
+	return links
+}
+
+// copyPail copies all the shards reachable from the root to another blockstore.
+func copyPail(t *testing.T, from block.Fetcher, to testutil.Blockstore, root ipld.Link) {
+	ctx := context.Background()
+	shards := shard.NewFetcher(from)
+	s, err := shards.Get(ctx, root)
+	require.NoError(t, err)
+
+	err = to.Put(ctx, s)
+	require.NoError(t, err)
+
+	for _, e := range s.Value().Entries() {
+		if e.Value().Shard() != nil {
+			copyPail(t, from, to, e.Value().Shard())
+		}
+	}
+}
+
+func links(blocks []shard.BlockView) []string {
+	var links []string
+	for _, b := range blocks {
+		links = append(links, b.Link().String())
+	}
+	return links
+}
+
+func contains(links []string, link string) bool {
+	for _, l := range links {
+		if l == link {
+			return true
+		}
+	}
+	return false
+}
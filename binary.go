@@ -0,0 +1,40 @@
+package pail
+
+import (
+	"context"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/shard"
+)
+
+// Keys of a pail with the [shard.KeyCharsBinary] key character set are
+// arbitrary byte strings. The functions that take a string key accept them as
+// Go strings holding the raw bytes, i.e. string(b), which are ordered by byte
+// value. The functions below take []byte keys for convenience.
+
+// PutBytes puts a value for a byte string key. See [Put].
+func PutBytes(ctx context.Context, blocks block.Fetcher, root ipld.Link, key []byte, value ipld.Link) (ipld.Link, shard.Diff, error) {
+	return Put(ctx, blocks, root, string(key), value)
+}
+
+// GetBytes gets the value for a byte string key. See [Get].
+func GetBytes(ctx context.Context, blocks block.Fetcher, root ipld.Link, key []byte) (ipld.Link, error) {
+	return Get(ctx, blocks, root, string(key))
+}
+
+// HasBytes determines if a value is stored for a byte string key. See [Has].
+func HasBytes(ctx context.Context, blocks block.Fetcher, root ipld.Link, key []byte) (bool, error) {
+	return Has(ctx, blocks, root, string(key))
+}
+
+// DelBytes deletes the value for a byte string key. See [Del].
+func DelBytes(ctx context.Context, blocks block.Fetcher, root ipld.Link, key []byte) (ipld.Link, shard.Diff, error) {
+	return Del(ctx, blocks, root, string(key))
+}
+
+// KeyBytes returns the key of the entry as a byte string, for pails with the
+// [shard.KeyCharsBinary] key character set.
+func (e Entry) KeyBytes() []byte {
+	return []byte(e.Key)
+}
@@ -0,0 +1,201 @@
+package pail
+
+import (
+	"context"
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryKeys(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("put, get and delete", func(t *testing.T) {
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsBinary))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+		v2 := testutil.RandomLink(t)
+		// "\xc3\xa4" and "\xc3\xa5" are "ä" and "å" in UTF-8, but in a binary pail
+		// they are split at the first byte
+		r0 := putAll(t, bs, rb0.Link(), []object{
+			{"\xc3\xa4", v0},
+			{"\xc3\xa5", v1},
+			{"\x00\xff", v2},
+		})
+
+		require.Equal(t, []entry{
+			{"\x00\xff", value{v2, nil}},
+			{
+				"\xc3",
+				value{
+					nil,
+					[]entry{
+						{"\xa4", value{v0, nil}},
+						{"\xa5", value{v1, nil}},
+					},
+				},
+			},
+		}, materialize(t, bs, r0))
+
+		v, err := Get(ctx, bs, r0, "\xc3\xa5")
+		require.NoError(t, err)
+		require.Equal(t, v1, v)
+
+		r1, diff, err := Del(ctx, bs, r0, "\xc3\xa5")
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+
+		_, err = Get(ctx, bs, r1, "\xc3\xa5")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		rshard, err := shard.NewFetcher(bs).GetRoot(ctx, r1)
+		require.NoError(t, err)
+		require.Equal(t, shard.KeyCharsBinary, rshard.Value().KeyChars())
+	})
+
+	t.Run("keys are encoded as bytes", func(t *testing.T) {
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsBinary))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+		r0 := putAll(t, bs, rb0.Link(), []object{{"aaaa", v0}, {"aabb", v1}})
+
+		shards := shard.NewFetcher(bs)
+		rshard, err := shards.GetRoot(ctx, r0)
+		require.NoError(t, err)
+		require.True(t, shard.IsBinary(rshard.Value()))
+
+		s, err := shards.Get(ctx, rshard.Value().Entries()[0].Value().Shard())
+		require.NoError(t, err)
+		require.True(t, shard.IsBinary(s.Value()))
+
+		// the same keys in an ASCII pail are encoded differently
+		ab0, err := New()
+		require.NoError(t, err)
+		err = bs.Put(ctx, ab0)
+		require.NoError(t, err)
+
+		a0 := putAll(t, bs, ab0.Link(), []object{{"aaaa", v0}, {"aabb", v1}})
+		require.NotEqual(t, r0.String(), a0.String())
+		require.Equal(t, materialize(t, bs, a0), materialize(t, bs, r0))
+	})
+
+	t.Run("entries in byte order", func(t *testing.T) {
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsBinary))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		// big endian encoded integers sort in numeric order
+		var objects []object
+		for i := range 300 {
+			objects = append(objects, object{string(binary.BigEndian.AppendUint32(nil, uint32(i*1000))), testutil.RandomLink(t)})
+		}
+		// random hashes
+		for range 100 {
+			objects = append(objects, object{string(testutil.RandomBytes(t, 32)), testutil.RandomLink(t)})
+		}
+		r0 := putAll(t, bs, rb0.Link(), objects)
+		slices.SortFunc(objects, objectKeySort)
+
+		var results []object
+		for e, err := range Entries(ctx, bs, r0) {
+			require.NoError(t, err)
+			results = append(results, object{e.Key, e.Value})
+		}
+		require.Equal(t, objects, results)
+
+		gte := string(binary.BigEndian.AppendUint32(nil, 10_000))
+		lt := string(binary.BigEndian.AppendUint32(nil, 20_000))
+		var keys []uint32
+		for e, err := range Entries(ctx, bs, r0, WithKeyGreaterThanOrEqual(gte), WithKeyLessThan(lt)) {
+			require.NoError(t, err)
+			keys = append(keys, binary.BigEndian.Uint32([]byte(e.Key)))
+		}
+		require.Len(t, keys, 10)
+		require.Equal(t, uint32(10_000), keys[0])
+		require.Equal(t, uint32(19_000), keys[9])
+	})
+
+	t.Run("from entries", func(t *testing.T) {
+		var objects []object
+		for range 200 {
+			objects = append(objects, object{string(testutil.RandomBytes(t, 4)), testutil.RandomLink(t)})
+		}
+		slices.SortFunc(objects, objectKeySort)
+		objects = slices.CompactFunc(objects, func(a, b object) bool { return a.key == b.key })
+
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsBinary))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		expect := putAll(t, bs, rb0.Link(), objects)
+
+		root, err := FromEntries(ctx, objectEntries(objects), func(b shard.BlockView) error {
+			return nil
+		}, shard.WithKeyChars(shard.KeyCharsBinary))
+		require.NoError(t, err)
+		require.Equal(t, expect.String(), root.String())
+	})
+
+	t.Run("byte slice keys", func(t *testing.T) {
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsBinary))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		k0 := []byte{0x00, 0xff, 0x10}
+		k1 := []byte{0x00, 0xff, 0x20}
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+
+		r0, diff, err := PutBytes(ctx, bs, rb0.Link(), k0, v0)
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+		r1, diff, err := PutBytes(ctx, bs, r0, k1, v1)
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+
+		v, err := GetBytes(ctx, bs, r1, k0)
+		require.NoError(t, err)
+		require.Equal(t, v0, v)
+		ok, err := HasBytes(ctx, bs, r1, k1)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		var keys [][]byte
+		for e, err := range Entries(ctx, bs, r1, WithKeyGreaterThan(string(k0))) {
+			require.NoError(t, err)
+			keys = append(keys, e.KeyBytes())
+		}
+		require.Equal(t, [][]byte{k1}, keys)
+
+		r2, diff, err := DelBytes(ctx, bs, r1, k0)
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+		_, err = GetBytes(ctx, bs, r2, k0)
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
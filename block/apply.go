@@ -0,0 +1,127 @@
+package block
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipld/go-ipld-prime"
+)
+
+// Changes are the blocks added and removed by an operation, for example the
+// diff returned when a pail is modified.
+type Changes interface {
+	// AddedBlocks are the blocks that must be stored.
+	AddedBlocks() []Block
+	// RemovedBlocks are the blocks that are no longer needed.
+	RemovedBlocks() []Block
+}
+
+// Batch is a group of writes to a [BatchingBlockstore] that are applied when
+// the batch is committed.
+type Batch interface {
+	Put(ctx context.Context, b Block) error
+	Del(ctx context.Context, link ipld.Link) error
+	// Commit applies the writes in the batch. Transactional backends apply all
+	// the writes or none of them.
+	Commit(ctx context.Context) error
+}
+
+// BatchingBlockstore is a [Blockstore] that can group writes into a batch.
+type BatchingBlockstore interface {
+	Blockstore
+	Batch(ctx context.Context) (Batch, error)
+}
+
+// RetainFunc determines if a block is still referenced, for example by the
+// root of another pail that shares the blockstore.
+type RetainFunc func(ctx context.Context, link ipld.Link) (bool, error)
+
+type ApplyOption func(*applyOptions)
+
+type applyOptions struct {
+	retain []RetainFunc
+}
+
+// WithRetain prevents removal of blocks that are still referenced, as
+// determined by the passed function. It may be passed multiple times.
+func WithRetain(retain RetainFunc) ApplyOption {
+	return func(o *applyOptions) {
+		o.retain = append(o.retain, retain)
+	}
+}
+
+// Apply stores the added blocks and then deletes the removed blocks. All the
+// added blocks are stored before any block is deleted, so an interruption
+// never leaves a pail with missing blocks, only unneeded ones. Blocks that are
+// both added and removed are not deleted.
+//
+// If the store is a [BatchingBlockstore] the changes are written in a single
+// batch, which is atomic for transactional backends.
+func Apply(ctx context.Context, store Blockstore, changes Changes, opts ...ApplyOption) error {
+	o := applyOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	added := map[string]struct{}{}
+	for _, b := range changes.AddedBlocks() {
+		added[b.Link().String()] = struct{}{}
+	}
+
+	var removals []ipld.Link
+	for _, b := range changes.RemovedBlocks() {
+		if _, ok := added[b.Link().String()]; ok {
+			continue
+		}
+		retained := false
+		for _, retain := range o.retain {
+			ok, err := retain(ctx, b.Link())
+			if err != nil {
+				return fmt.Errorf("checking if block %s is retained: %w", b.Link().String(), err)
+			}
+			if ok {
+				retained = true
+				break
+			}
+		}
+		if !retained {
+			removals = append(removals, b.Link())
+		}
+	}
+
+	var w interface {
+		Put(ctx context.Context, b Block) error
+		Del(ctx context.Context, link ipld.Link) error
+	} = store
+	batch, ok := store.(BatchingBlockstore)
+	var bt Batch
+	if ok {
+		var err error
+		bt, err = batch.Batch(ctx)
+		if err != nil {
+			return fmt.Errorf("creating batch: %w", err)
+		}
+		w = bt
+	}
+
+	for _, b := range changes.AddedBlocks() {
+		err := w.Put(ctx, b)
+		if err != nil {
+			return fmt.Errorf("putting block %s: %w", b.Link().String(), err)
+		}
+	}
+	for _, l := range removals {
+		err := w.Del(ctx, l)
+		if err != nil {
+			return fmt.Errorf("deleting block %s: %w", l.String(), err)
+		}
+	}
+
+	if bt != nil {
+		err := bt.Commit(ctx)
+		if err != nil {
+			return fmt.Errorf("committing batch: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package block_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/stretchr/testify/require"
+)
+
+type changes struct {
+	added   []block.Block
+	removed []block.Block
+}
+
+func (c changes) AddedBlocks() []block.Block   { return c.added }
+func (c changes) RemovedBlocks() []block.Block { return c.removed }
+
+// recordingBlockstore records the order of writes to the underlying store.
+type recordingBlockstore struct {
+	*block.MapBlockstore
+	ops []string
+}
+
+func (bs *recordingBlockstore) Put(ctx context.Context, b block.Block) error {
+	bs.ops = append(bs.ops, "put "+b.Link().String())
+	return bs.MapBlockstore.Put(ctx, b)
+}
+
+func (bs *recordingBlockstore) Del(ctx context.Context, link ipld.Link) error {
+	bs.ops = append(bs.ops, "del "+link.String())
+	return bs.MapBlockstore.Del(ctx, link)
+}
+
+// txnBlockstore buffers writes in a batch that is applied on commit, or
+// discarded if commit fails.
+type txnBlockstore struct {
+	*block.MapBlockstore
+	commitErr error
+}
+
+type txn struct {
+	bs   *txnBlockstore
+	puts []block.Block
+	dels []ipld.Link
+}
+
+func (bs *txnBlockstore) Batch(ctx context.Context) (block.Batch, error) {
+	return &txn{bs: bs}, nil
+}
+
+func (t *txn) Put(ctx context.Context, b block.Block) error {
+	t.puts = append(t.puts, b)
+	return nil
+}
+
+func (t *txn) Del(ctx context.Context, link ipld.Link) error {
+	t.dels = append(t.dels, link)
+	return nil
+}
+
+func (t *txn) Commit(ctx context.Context) error {
+	if t.bs.commitErr != nil {
+		return t.bs.commitErr
+	}
+	for _, b := range t.puts {
+		err := t.bs.MapBlockstore.Put(ctx, b)
+		if err != nil {
+			return err
+		}
+	}
+	for _, l := range t.dels {
+		err := t.bs.MapBlockstore.Del(ctx, l)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ block.BatchingBlockstore = (*txnBlockstore)(nil)
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("additions before removals", func(t *testing.T) {
+		bs := &recordingBlockstore{MapBlockstore: block.NewMapBlockstore()}
+		a, b, c, d := randomBlock(t), randomBlock(t), randomBlock(t), randomBlock(t)
+		for _, blk := range []block.Block{c, d} {
+			err := bs.MapBlockstore.Put(ctx, blk)
+			require.NoError(t, err)
+		}
+
+		err := block.Apply(ctx, bs, changes{added: []block.Block{a, b}, removed: []block.Block{c, d}})
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"put " + a.Link().String(),
+			"put " + b.Link().String(),
+			"del " + c.Link().String(),
+			"del " + d.Link().String(),
+		}, bs.ops)
+	})
+
+	t.Run("block added and removed is kept", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		a := randomBlock(t)
+		err := bs.Put(ctx, a)
+		require.NoError(t, err)
+
+		err = block.Apply(ctx, bs, changes{added: []block.Block{a}, removed: []block.Block{a}})
+		require.NoError(t, err)
+		ok, err := bs.Has(ctx, a.Link())
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+
+	t.Run("retained blocks are not removed", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		a, b := randomBlock(t), randomBlock(t)
+		for _, blk := range []block.Block{a, b} {
+			err := bs.Put(ctx, blk)
+			require.NoError(t, err)
+		}
+
+		retain := func(ctx context.Context, link ipld.Link) (bool, error) {
+			return link.String() == a.Link().String(), nil
+		}
+		err := block.Apply(ctx, bs, changes{removed: []block.Block{a, b}}, block.WithRetain(retain))
+		require.NoError(t, err)
+
+		ok, err := bs.Has(ctx, a.Link())
+		require.NoError(t, err)
+		require.True(t, ok)
+		ok, err = bs.Has(ctx, b.Link())
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("retain error", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		a := randomBlock(t)
+		err := bs.Put(ctx, a)
+		require.NoError(t, err)
+
+		boom := errors.New("boom")
+		retain := func(ctx context.Context, link ipld.Link) (bool, error) {
+			return false, boom
+		}
+		err = block.Apply(ctx, bs, changes{removed: []block.Block{a}}, block.WithRetain(retain))
+		require.ErrorIs(t, err, boom)
+
+		// nothing is written when the removals cannot be determined
+		ok, err := bs.Has(ctx, a.Link())
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+
+	t.Run("transactional backend", func(t *testing.T) {
+		bs := &txnBlockstore{MapBlockstore: block.NewMapBlockstore()}
+		a, b := randomBlock(t), randomBlock(t)
+		err := bs.MapBlockstore.Put(ctx, b)
+		require.NoError(t, err)
+
+		bs.commitErr = errors.New("conflict")
+		err = block.Apply(ctx, bs, changes{added: []block.Block{a}, removed: []block.Block{b}})
+		require.ErrorIs(t, err, bs.commitErr)
+
+		// a failed commit leaves the store unchanged
+		ok, err := bs.Has(ctx, a.Link())
+		require.NoError(t, err)
+		require.False(t, ok)
+		ok, err = bs.Has(ctx, b.Link())
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		bs.commitErr = nil
+		err = block.Apply(ctx, bs, changes{added: []block.Block{a}, removed: []block.Block{b}})
+		require.NoError(t, err)
+
+		ok, err = bs.Has(ctx, a.Link())
+		require.NoError(t, err)
+		require.True(t, ok)
+		ok, err = bs.Has(ctx, b.Link())
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+}
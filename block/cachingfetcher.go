@@ -0,0 +1,173 @@
+package block
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/ipld/go-ipld-prime"
+)
+
+// ViewCache is a cache of decoded views of blocks, such as decoded shards and
+// events. Views are keyed by CID and by the kind of view, so that different
+// views of the same block, for example a shard decoded as a root shard and as a
+// non-root shard, do not replace each other.
+type ViewCache interface {
+	// GetView returns the cached view of the kind for the block with the
+	// passed link, if there is one.
+	GetView(link ipld.Link, kind string) (Block, bool)
+	// AddView adds a view of the kind to the cache.
+	AddView(kind string, view Block)
+}
+
+// ViewCacheOf returns the [ViewCache] of the fetcher, which is either the
+// fetcher itself or the first view cache found in the fetchers it wraps, for
+// example the tiers of a [TieredBlockFetcher]. Fetchers that wrap other
+// fetchers expose them with an Unwrap() []Fetcher method.
+func ViewCacheOf(f Fetcher) (ViewCache, bool) {
+	if c, ok := f.(ViewCache); ok {
+		return c, true
+	}
+	if u, ok := f.(interface{ Unwrap() []Fetcher }); ok {
+		for _, f := range u.Unwrap() {
+			if c, ok := ViewCacheOf(f); ok {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// CacheStats are the number of cache hits and misses of a [CachingFetcher].
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// CachingFetcher is a [Fetcher] that keeps the most recently used blocks in a
+// size-bounded LRU cache keyed by CID. Blocks are content addressed, so cached
+// blocks never need to be invalidated. It is safe for concurrent use.
+//
+// It is also a [ViewCache]. Decoding fetchers such as the shard and event
+// fetchers store the views they decode in the cache, so that subsequent
+// fetches return the decoded view without decoding the block again. Views are
+// cached together with the block they were decoded from, and are evicted with
+// it, so the size of the cache is the number of blocks it holds.
+type CachingFetcher struct {
+	blocks Fetcher
+	size   int
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used at the front
+	stats   CacheStats
+}
+
+// cacheEntry is a cached block and the views decoded from it.
+type cacheEntry struct {
+	key   string
+	block Block
+	views map[string]Block
+}
+
+func (cf *CachingFetcher) Get(ctx context.Context, link ipld.Link) (Block, error) {
+	if b, ok := cf.get(link, ""); ok {
+		return b, nil
+	}
+	cf.mutex.Lock()
+	cf.stats.Misses++
+	cf.mutex.Unlock()
+
+	b, err := cf.blocks.Get(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	cf.add(b, "")
+	return b, nil
+}
+
+// GetView returns the cached view of the kind for the block with the passed
+// link. A view that is found is counted as a cache hit, a view that is not
+// found is not counted as a miss, since the block is then usually fetched and
+// decoded.
+func (cf *CachingFetcher) GetView(link ipld.Link, kind string) (Block, bool) {
+	return cf.get(link, kind)
+}
+
+// AddView adds a view of the kind to the cache, replacing a cached view of the
+// same kind for the same block. A view of a block that is not cached caches the
+// block too.
+func (cf *CachingFetcher) AddView(kind string, view Block) {
+	cf.add(view, kind)
+}
+
+// get returns the cached block with the passed link, or the view of the kind
+// if kind is not empty.
+func (cf *CachingFetcher) get(link ipld.Link, kind string) (Block, bool) {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+	el, ok := cf.entries[link.String()]
+	if !ok {
+		return nil, false
+	}
+	ent := el.Value.(*cacheEntry)
+	b := ent.block
+	if kind != "" {
+		if b, ok = ent.views[kind]; !ok {
+			return nil, false
+		}
+	}
+	cf.order.MoveToFront(el)
+	cf.stats.Hits++
+	return b, true
+}
+
+// add caches the block, or a view of the kind of it if kind is not empty.
+func (cf *CachingFetcher) add(b Block, kind string) {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	key := b.Link().String()
+	el, ok := cf.entries[key]
+	if ok {
+		cf.order.MoveToFront(el)
+	} else {
+		// a view is also the block it was decoded from
+		el = cf.order.PushFront(&cacheEntry{key: key, block: b, views: map[string]Block{}})
+		cf.entries[key] = el
+	}
+	if kind != "" {
+		el.Value.(*cacheEntry).views[kind] = b
+	}
+	for cf.order.Len() > cf.size {
+		el := cf.order.Back()
+		cf.order.Remove(el)
+		delete(cf.entries, el.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns the number of cached blocks, not counting their views.
+func (cf *CachingFetcher) Len() int {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+	return cf.order.Len()
+}
+
+// Stats returns the number of cache hits and misses.
+func (cf *CachingFetcher) Stats() CacheStats {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+	return cf.stats
+}
+
+// NewCachingFetcher creates a [Fetcher] that caches up to size of the most
+// recently used blocks fetched from the passed fetcher, along with the views
+// decoded from them.
+func NewCachingFetcher(blocks Fetcher, size int) *CachingFetcher {
+	return &CachingFetcher{
+		blocks:  blocks,
+		size:    max(size, 1),
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
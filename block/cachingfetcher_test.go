@@ -0,0 +1,297 @@
+package block_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/clock/event"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/ipld/node"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+var errRejected = errors.New("rejected")
+
+// newBinder creates a binder that returns the node, or rejects it if strict.
+func newBinder(strict bool) node.BinderFunc[ipld.Node] {
+	return func(n ipld.Node) (ipld.Node, error) {
+		if strict {
+			return nil, errRejected
+		}
+		return n, nil
+	}
+}
+
+func TestCachingFetcher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("hits and misses", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		b := randomBlock(t)
+		err := bs.Put(ctx, b)
+		require.NoError(t, err)
+
+		cf := block.NewCachingFetcher(bs, 10)
+		for range 3 {
+			g, err := cf.Get(ctx, b.Link())
+			require.NoError(t, err)
+			require.Equal(t, b.Bytes(), g.Bytes())
+		}
+		require.Equal(t, block.CacheStats{Hits: 2, Misses: 1}, cf.Stats())
+
+		// blocks that are not found are not cached
+		_, err = cf.Get(ctx, randomBlock(t).Link())
+		require.ErrorIs(t, err, block.ErrNotFound)
+		require.Equal(t, block.CacheStats{Hits: 2, Misses: 2}, cf.Stats())
+		require.Equal(t, 1, cf.Len())
+	})
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		var blocks []block.Block
+		for range 3 {
+			b := randomBlock(t)
+			err := bs.Put(ctx, b)
+			require.NoError(t, err)
+			blocks = append(blocks, b)
+		}
+
+		cf := block.NewCachingFetcher(bs, 2)
+		for _, b := range blocks[:2] {
+			_, err := cf.Get(ctx, b.Link())
+			require.NoError(t, err)
+		}
+		// use the first block so that the second is least recently used
+		_, err := cf.Get(ctx, blocks[0].Link())
+		require.NoError(t, err)
+		_, err = cf.Get(ctx, blocks[2].Link())
+		require.NoError(t, err)
+		require.Equal(t, 2, cf.Len())
+		require.Equal(t, block.CacheStats{Hits: 1, Misses: 3}, cf.Stats())
+
+		_, err = cf.Get(ctx, blocks[0].Link())
+		require.NoError(t, err)
+		require.Equal(t, block.CacheStats{Hits: 2, Misses: 3}, cf.Stats())
+		_, err = cf.Get(ctx, blocks[1].Link())
+		require.NoError(t, err)
+		require.Equal(t, block.CacheStats{Hits: 2, Misses: 4}, cf.Stats())
+	})
+
+	t.Run("caches decoded shards", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		sb, err := shard.MarshalBlock(shard.New("a", []shard.Entry{
+			shard.NewEntry("b", shard.NewValue(testutil.RandomLink(t), nil)),
+		}))
+		require.NoError(t, err)
+		err = bs.Put(ctx, sb)
+		require.NoError(t, err)
+
+		cf := block.NewCachingFetcher(bs, 10)
+		shards := shard.NewFetcher(cf)
+		s0, err := shards.Get(ctx, sb.Link())
+		require.NoError(t, err)
+		require.Equal(t, block.CacheStats{Hits: 0, Misses: 1}, cf.Stats())
+
+		// the decoded view is cached with the block
+		require.Equal(t, 1, cf.Len())
+		s1, err := shards.Get(ctx, sb.Link())
+		require.NoError(t, err)
+		require.Equal(t, s0.Value(), s1.Value())
+		require.Equal(t, block.CacheStats{Hits: 1, Misses: 1}, cf.Stats())
+
+		// strict fetchers decode the cached block again
+		s2, err := shard.NewFetcher(cf, shard.WithStrict()).Get(ctx, sb.Link())
+		require.NoError(t, err)
+		require.Equal(t, s0.Value(), s2.Value())
+		require.Equal(t, block.CacheStats{Hits: 2, Misses: 1}, cf.Stats())
+	})
+
+	t.Run("caches root and non-root views separately", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		rb, err := shard.MarshalBlock(shard.NewRoot([]shard.Entry{
+			shard.NewEntry("a", shard.NewValue(testutil.RandomLink(t), nil)),
+		}))
+		require.NoError(t, err)
+		err = bs.Put(ctx, rb)
+		require.NoError(t, err)
+
+		cf := block.NewCachingFetcher(bs, 10)
+		shards := shard.NewFetcher(cf)
+		for range 3 {
+			_, err := shards.GetRoot(ctx, rb.Link())
+			require.NoError(t, err)
+			_, err = shards.Get(ctx, rb.Link())
+			require.NoError(t, err)
+		}
+		// the block is fetched once, then each view is decoded once
+		require.Equal(t, block.CacheStats{Hits: 5, Misses: 1}, cf.Stats())
+		require.Equal(t, 1, cf.Len())
+	})
+
+	t.Run("evicts views with their block", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		var blocks []shard.BlockView
+		for _, p := range []string{"a", "b", "c"} {
+			sb, err := shard.MarshalBlock(shard.New(p, []shard.Entry{
+				shard.NewEntry("x", shard.NewValue(testutil.RandomLink(t), nil)),
+			}))
+			require.NoError(t, err)
+			err = bs.Put(ctx, sb)
+			require.NoError(t, err)
+			blocks = append(blocks, sb)
+		}
+
+		// a cache of size 2 holds the views of 2 shards
+		cf := block.NewCachingFetcher(bs, 2)
+		shards := shard.NewFetcher(cf)
+		for _, sb := range blocks {
+			_, err := shards.Get(ctx, sb.Link())
+			require.NoError(t, err)
+		}
+		require.Equal(t, 2, cf.Len())
+		require.Equal(t, block.CacheStats{Hits: 0, Misses: 3}, cf.Stats())
+
+		for _, sb := range blocks[1:] {
+			_, err := shards.Get(ctx, sb.Link())
+			require.NoError(t, err)
+		}
+		require.Equal(t, block.CacheStats{Hits: 2, Misses: 3}, cf.Stats())
+
+		// the first shard was evicted with its view
+		_, err := shards.Get(ctx, blocks[0].Link())
+		require.NoError(t, err)
+		require.Equal(t, block.CacheStats{Hits: 2, Misses: 4}, cf.Stats())
+	})
+
+	t.Run("caches decoded events", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		binder := testutil.NewTestEventBinder(t)
+		eb, err := event.MarshalBlock(event.NewEvent(testutil.RandomEventData(t), nil), binder)
+		require.NoError(t, err)
+		err = bs.Put(ctx, eb)
+		require.NoError(t, err)
+
+		cf := block.NewCachingFetcher(bs, 10)
+		events := event.NewFetcher(cf, binder, event.WithCacheKey("test"))
+		e0, err := events.Get(ctx, eb.Link())
+		require.NoError(t, err)
+		e1, err := events.Get(ctx, eb.Link())
+		require.NoError(t, err)
+		require.Equal(t, e0.Value(), e1.Value())
+		require.Equal(t, block.CacheStats{Hits: 1, Misses: 1}, cf.Stats())
+
+		// events are not cached without a cache key
+		events = event.NewFetcher(cf, binder)
+		for range 2 {
+			_, err = events.Get(ctx, eb.Link())
+			require.NoError(t, err)
+		}
+		require.Equal(t, block.CacheStats{Hits: 3, Misses: 1}, cf.Stats())
+	})
+
+	t.Run("caches events separately for each cache key", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		eb, err := event.MarshalBlock(event.NewEvent(ipld.Node(basicnode.NewString("data")), nil), node.UnbinderFunc[ipld.Node](func(n ipld.Node) (ipld.Node, error) {
+			return n, nil
+		}))
+		require.NoError(t, err)
+		err = bs.Put(ctx, block.New(eb.Link(), eb.Bytes()))
+		require.NoError(t, err)
+
+		cf := block.NewCachingFetcher(bs, 10)
+		_, err = event.NewFetcher(cf, newBinder(false), event.WithCacheKey("lenient")).Get(ctx, eb.Link())
+		require.NoError(t, err)
+
+		// a strict binder does not get the view bound by the lenient one, although
+		// both are closures of the same function literal
+		_, err = event.NewFetcher(cf, newBinder(true), event.WithCacheKey("strict")).Get(ctx, eb.Link())
+		require.ErrorIs(t, err, errRejected)
+		_, err = event.NewFetcher(cf, newBinder(true)).Get(ctx, eb.Link())
+		require.ErrorIs(t, err, errRejected)
+	})
+
+	t.Run("does not reuse views from the store", func(t *testing.T) {
+		sb, err := shard.MarshalBlock(shard.New("a", []shard.Entry{
+			shard.NewEntry("b", shard.NewValue(testutil.RandomLink(t), nil)),
+		}))
+		require.NoError(t, err)
+
+		// a view that does not match the bytes of the block
+		bs := block.NewMapBlockstore()
+		err = bs.Put(ctx, block.NewBlockView(sb.Link(), sb.Bytes(), shard.New("x", nil)))
+		require.NoError(t, err)
+
+		for _, f := range []block.Fetcher{bs, block.NewCachingFetcher(bs, 10)} {
+			s, err := shard.NewFetcher(f).Get(ctx, sb.Link())
+			require.NoError(t, err)
+			require.Equal(t, sb.Value(), s.Value())
+		}
+	})
+
+	t.Run("wrapped by other fetchers", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		sb, err := shard.MarshalBlock(shard.New("a", []shard.Entry{
+			shard.NewEntry("b", shard.NewValue(testutil.RandomLink(t), nil)),
+		}))
+		require.NoError(t, err)
+		err = bs.Put(ctx, sb)
+		require.NoError(t, err)
+
+		cf := block.NewCachingFetcher(bs, 10)
+		fetchers := []block.Fetcher{
+			block.NewTieredBlockFetcher(block.NewMapBlockstore(), cf),
+			block.NewRaceFetcher(cf),
+		}
+		for _, f := range fetchers {
+			c, ok := block.ViewCacheOf(f)
+			require.True(t, ok)
+			require.Equal(t, block.ViewCache(cf), c)
+		}
+		_, ok := block.ViewCacheOf(bs)
+		require.False(t, ok)
+
+		shards := shard.NewFetcher(fetchers[0])
+		for range 3 {
+			_, err := shards.Get(ctx, sb.Link())
+			require.NoError(t, err)
+		}
+		require.Equal(t, block.CacheStats{Hits: 2, Misses: 1}, cf.Stats())
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		var blocks []block.Block
+		for range 20 {
+			b := randomBlock(t)
+			err := bs.Put(ctx, b)
+			require.NoError(t, err)
+			blocks = append(blocks, b)
+		}
+
+		cf := block.NewCachingFetcher(bs, 5)
+		var wg sync.WaitGroup
+		for i := range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range 100 {
+					b := blocks[(i+j)%len(blocks)]
+					g, err := cf.Get(ctx, b.Link())
+					require.NoError(t, err)
+					require.Equal(t, b.Bytes(), g.Bytes())
+				}
+			}()
+		}
+		wg.Wait()
+
+		stats := cf.Stats()
+		require.Equal(t, uint64(1000), stats.Hits+stats.Misses)
+		require.Equal(t, 5, cf.Len())
+	})
+}
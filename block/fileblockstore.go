@@ -0,0 +1,268 @@
+package block
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+)
+
+// tempPrefix is the prefix of the names of files that are being written.
+const tempPrefix = ".tmp-"
+
+var fileNameEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// FileBlockstore is a [Blockstore] that stores blocks as files in a directory.
+//
+// Blocks are keyed by multihash, so a block is found by any CID with the same
+// multihash. Each file is named by the base32 encoded multihash and stored in
+// a sub-directory named by the last two characters of the file name, to limit
+// the number of files in a single directory. A file contains the CID of the
+// block followed by the block data, so that blocks can be listed with their
+// CIDs.
+//
+// Blocks are written to a temporary file that is synced to disk and then
+// renamed, and the directory is synced after a file is added or removed, so a
+// block is either completely stored or not stored at all, even if the process
+// crashes or the machine loses power.
+type FileBlockstore struct {
+	dir string
+}
+
+// NewFileBlockstore creates a blockstore that stores blocks as files in the
+// passed directory, which is created if it does not exist. Temporary files
+// left behind by writes that were interrupted are removed.
+func NewFileBlockstore(dir string) (*FileBlockstore, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("creating directory: %w", err)
+	}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasPrefix(d.Name(), tempPrefix) {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("removing temporary files: %w", err)
+	}
+	return &FileBlockstore{dir}, nil
+}
+
+func (bs *FileBlockstore) Get(ctx context.Context, link ipld.Link) (Block, error) {
+	path, err := bs.path(link)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("reading block: %w", err)
+	}
+	_, bytes, err := decodeFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding block file %s: %w", path, err)
+	}
+	return New(link, bytes), nil
+}
+
+func (bs *FileBlockstore) Put(ctx context.Context, b Block) error {
+	path, err := bs.path(b.Link())
+	if err != nil {
+		return err
+	}
+	// blocks are immutable, so an existing file does not need to be written
+	_, err = os.Stat(path)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("checking for block: %w", err)
+	}
+
+	c, err := toCid(b.Link())
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	_, err = os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = os.Mkdir(dir, 0o755)
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("creating directory: %w", err)
+		}
+		err = syncDir(bs.dir)
+	}
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op once renamed
+
+	_, err = f.Write(c.Bytes())
+	if err == nil {
+		_, err = f.Write(b.Bytes())
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	cerr := f.Close()
+	if err != nil {
+		return fmt.Errorf("writing block: %w", err)
+	}
+	if cerr != nil {
+		return fmt.Errorf("closing block file: %w", cerr)
+	}
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return fmt.Errorf("renaming block file: %w", err)
+	}
+	return syncDir(dir)
+}
+
+func (bs *FileBlockstore) Del(ctx context.Context, link ipld.Link) error {
+	path, err := bs.path(link)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("removing block: %w", err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func (bs *FileBlockstore) Has(ctx context.Context, link ipld.Link) (bool, error) {
+	path, err := bs.path(link)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("checking for block: %w", err)
+	}
+	return true, nil
+}
+
+func (bs *FileBlockstore) Entries(ctx context.Context) iter.Seq2[Block, error] {
+	return func(yield func(Block, error) bool) {
+		dirs, err := os.ReadDir(bs.dir)
+		if err != nil {
+			yield(nil, fmt.Errorf("reading directory: %w", err))
+			return
+		}
+		for _, d := range dirs {
+			if !d.IsDir() {
+				continue
+			}
+			files, err := os.ReadDir(filepath.Join(bs.dir, d.Name()))
+			if err != nil {
+				yield(nil, fmt.Errorf("reading directory: %w", err))
+				return
+			}
+			for _, f := range files {
+				if f.IsDir() || strings.HasPrefix(f.Name(), tempPrefix) {
+					continue
+				}
+				if err := ctx.Err(); err != nil {
+					yield(nil, err)
+					return
+				}
+				path := filepath.Join(bs.dir, d.Name(), f.Name())
+				data, err := os.ReadFile(path)
+				if err != nil {
+					// removed since the directory was read
+					if errors.Is(err, fs.ErrNotExist) {
+						continue
+					}
+					yield(nil, fmt.Errorf("reading block: %w", err))
+					return
+				}
+				c, bytes, err := decodeFile(data)
+				if err != nil {
+					yield(nil, fmt.Errorf("decoding block file %s: %w", path, err))
+					return
+				}
+				if !yield(New(cidlink.Link{Cid: c}, bytes), nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// path returns the path of the file for the block with the passed link.
+func (bs *FileBlockstore) path(link ipld.Link) (string, error) {
+	c, err := toCid(link)
+	if err != nil {
+		return "", err
+	}
+	name := fileNameEncoding.EncodeToString(c.Hash())
+	return filepath.Join(bs.dir, name[len(name)-2:], name), nil
+}
+
+func toCid(link ipld.Link) (cid.Cid, error) {
+	if cl, ok := link.(cidlink.Link); ok {
+		return cl.Cid, nil
+	}
+	c, err := cid.Parse(link.String())
+	if err != nil {
+		return cid.Undef, fmt.Errorf("parsing link as CID: %w", err)
+	}
+	return c, nil
+}
+
+// decodeFile decodes the CID and data of a block stored in a file.
+func decodeFile(data []byte) (cid.Cid, []byte, error) {
+	n, c, err := cid.CidFromBytes(data)
+	if err != nil {
+		return cid.Undef, nil, fmt.Errorf("decoding CID: %w", err)
+	}
+	return c, data[n:], nil
+}
+
+// syncDir flushes changes to the entries of a directory to disk, so that files
+// that were added or removed are not lost in a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("opening directory: %w", err)
+	}
+	err = d.Sync()
+	cerr := d.Close()
+	if err != nil {
+		return fmt.Errorf("syncing directory: %w", err)
+	}
+	if cerr != nil {
+		return fmt.Errorf("closing directory: %w", cerr)
+	}
+	return nil
+}
+
+var _ Blockstore = (*FileBlockstore)(nil)
@@ -0,0 +1,140 @@
+package block_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/ipld/multicodec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileBlockstore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("put, get, has and delete", func(t *testing.T) {
+		bs, err := block.NewFileBlockstore(t.TempDir())
+		require.NoError(t, err)
+
+		b := randomBlock(t)
+		ok, err := bs.Has(ctx, b.Link())
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		_, err = bs.Get(ctx, b.Link())
+		require.ErrorIs(t, err, block.ErrNotFound)
+
+		err = bs.Put(ctx, b)
+		require.NoError(t, err)
+		// putting an existing block is not an error
+		err = bs.Put(ctx, b)
+		require.NoError(t, err)
+
+		ok, err = bs.Has(ctx, b.Link())
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		g, err := bs.Get(ctx, b.Link())
+		require.NoError(t, err)
+		require.Equal(t, b.Link(), g.Link())
+		require.Equal(t, b.Bytes(), g.Bytes())
+
+		err = bs.Del(ctx, b.Link())
+		require.NoError(t, err)
+		// deleting a missing block is not an error
+		err = bs.Del(ctx, b.Link())
+		require.NoError(t, err)
+
+		_, err = bs.Get(ctx, b.Link())
+		require.ErrorIs(t, err, block.ErrNotFound)
+	})
+
+	t.Run("keyed by multihash", func(t *testing.T) {
+		bs, err := block.NewFileBlockstore(t.TempDir())
+		require.NoError(t, err)
+
+		b := randomBlock(t)
+		err = bs.Put(ctx, b)
+		require.NoError(t, err)
+
+		raw := cidlink.Link{Cid: cid.NewCidV1(cid.Raw, b.Link().(cidlink.Link).Hash())}
+		g, err := bs.Get(ctx, raw)
+		require.NoError(t, err)
+		require.Equal(t, raw, g.Link())
+		require.Equal(t, b.Bytes(), g.Bytes())
+	})
+
+	t.Run("entries persist", func(t *testing.T) {
+		dir := t.TempDir()
+		bs, err := block.NewFileBlockstore(dir)
+		require.NoError(t, err)
+
+		expect := map[string][]byte{}
+		for range 20 {
+			b := randomBlock(t)
+			err = bs.Put(ctx, b)
+			require.NoError(t, err)
+			expect[b.Link().String()] = b.Bytes()
+		}
+
+		// a write that was interrupted before the file was renamed
+		b := randomBlock(t)
+		err = bs.Put(ctx, b)
+		require.NoError(t, err)
+		path := blockPath(t, dir, b.Link())
+		err = os.Rename(path, filepath.Join(filepath.Dir(path), ".tmp-123"))
+		require.NoError(t, err)
+
+		bs, err = block.NewFileBlockstore(dir)
+		require.NoError(t, err)
+
+		_, err = os.Stat(filepath.Join(filepath.Dir(path), ".tmp-123"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		actual := map[string][]byte{}
+		for b, err := range bs.Entries(ctx) {
+			require.NoError(t, err)
+			actual[b.Link().String()] = b.Bytes()
+		}
+		require.Equal(t, expect, actual)
+	})
+}
+
+func randomBlock(t *testing.T) block.Block {
+	data := testutil.RandomBytes(t, 128)
+	digest, err := multihash.Sum(data, multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	return block.New(cidlink.Link{Cid: cid.NewCidV1(uint64(multicodec.DagCbor), digest)}, data)
+}
+
+// blockPath finds the path of the file a block is stored in.
+func blockPath(t *testing.T, dir string, link ipld.Link) string {
+	var path string
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		_, c, err := cid.CidFromBytes(data)
+		if err == nil && c.String() == link.String() {
+			path = p
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, path)
+	return path
+}
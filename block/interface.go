@@ -2,6 +2,7 @@ package block
 
 import (
 	"context"
+	"iter"
 
 	"github.com/ipld/go-ipld-prime"
 )
@@ -19,3 +20,23 @@ type BlockView[T any] interface {
 type Fetcher interface {
 	Get(ctx context.Context, link ipld.Link) (Block, error)
 }
+
+// Blockstore is a [Fetcher] that blocks can also be written to, for example
+// the additions of a shard diff, and removed from.
+type Blockstore interface {
+	Fetcher
+	// Put stores a block. Storing a block that already exists is not an error.
+	Put(ctx context.Context, b Block) error
+	// Del removes a block. Removing a block that does not exist is not an error.
+	Del(ctx context.Context, link ipld.Link) error
+	// Has determines if a block is stored.
+	Has(ctx context.Context, link ipld.Link) (bool, error)
+	// Entries iterates over all the stored blocks, in no particular order.
+	Entries(ctx context.Context) iter.Seq2[Block, error]
+}
+
+// Putter stores blocks.
+type Putter interface {
+	// Put stores a block. Storing a block that already exists is not an error.
+	Put(ctx context.Context, b Block) error
+}
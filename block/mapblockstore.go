@@ -8,7 +8,7 @@ import (
 	"github.com/ipld/go-ipld-prime"
 )
 
-// MapBlockstore is a blockstore that is backed by an in memory map.
+// MapBlockstore is a [Blockstore] that is backed by an in memory map.
 type MapBlockstore struct {
 	data  map[ipld.Link]Block
 	mutex sync.RWMutex
@@ -39,6 +39,14 @@ func (bs *MapBlockstore) Del(ctx context.Context, link ipld.Link) error {
 	return nil
 }
 
+func (bs *MapBlockstore) Has(ctx context.Context, link ipld.Link) (bool, error) {
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+
+	_, ok := bs.data[link]
+	return ok, nil
+}
+
 func (bs *MapBlockstore) Entries(ctx context.Context) iter.Seq2[Block, error] {
 	return func(yield func(Block, error) bool) {
 		bs.mutex.RLock()
@@ -55,3 +63,5 @@ func (bs *MapBlockstore) Entries(ctx context.Context) iter.Seq2[Block, error] {
 func NewMapBlockstore() *MapBlockstore {
 	return &MapBlockstore{map[ipld.Link]Block{}, sync.RWMutex{}}
 }
+
+var _ Blockstore = (*MapBlockstore)(nil)
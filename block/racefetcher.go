@@ -0,0 +1,78 @@
+package block
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ipld/go-ipld-prime"
+)
+
+// RaceFetcher is a [Fetcher] that attempts to retrieve a block from multiple
+// configured fetchers concurrently, returning the first [Block] that is
+// returned by a fetcher and cancelling the requests to the other fetchers.
+type RaceFetcher struct {
+	fetchers []Fetcher
+}
+
+type raceResult struct {
+	index int
+	block Block
+	err   error
+}
+
+// Get returns the first block that is retrieved by a fetcher. If every fetcher
+// fails, the errors of all the fetchers are combined with [errors.Join]. The
+// returned error matches [ErrNotFound] with [errors.Is] only if every fetcher
+// reported the block as not found, so callers can tell a missing block from a
+// failure that may be retried.
+func (rf *RaceFetcher) Get(ctx context.Context, link ipld.Link) (Block, error) {
+	if len(rf.fetchers) == 0 {
+		return nil, ErrNotFound
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffered so that fetchers that finish after a winner do not block
+	results := make(chan raceResult, len(rf.fetchers))
+	for i, f := range rf.fetchers {
+		go func() {
+			b, err := f.Get(ctx, link)
+			results <- raceResult{i, b, err}
+		}()
+	}
+
+	errs := make([]error, len(rf.fetchers))
+	notFound := true
+	for range rf.fetchers {
+		r := <-results
+		if r.err == nil {
+			return r.block, nil
+		}
+		errs[r.index] = r.err
+		notFound = notFound && errors.Is(r.err, ErrNotFound)
+	}
+	for i, err := range errs {
+		// if another fetcher failed the block may exist, so the not found errors
+		// are reported without wrapping ErrNotFound
+		if !notFound && errors.Is(err, ErrNotFound) {
+			errs[i] = fmt.Errorf("fetcher %d: %v", i, err)
+		} else {
+			errs[i] = fmt.Errorf("fetcher %d: %w", i, err)
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Unwrap returns the fetchers that are raced.
+func (rf *RaceFetcher) Unwrap() []Fetcher {
+	return rf.fetchers
+}
+
+// NewRaceFetcher creates a new [RaceFetcher] - a [Fetcher] that attempts to
+// retrieve a block from multiple configured fetchers concurrently, returning
+// the first [Block] that is returned by a fetcher.
+func NewRaceFetcher(fetchers ...Fetcher) *RaceFetcher {
+	return &RaceFetcher{fetchers}
+}
@@ -0,0 +1,93 @@
+package block_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/stretchr/testify/require"
+)
+
+type fetcherFunc func(ctx context.Context, link ipld.Link) (block.Block, error)
+
+func (f fetcherFunc) Get(ctx context.Context, link ipld.Link) (block.Block, error) {
+	return f(ctx, link)
+}
+
+// blockingFetcher blocks until the context is cancelled, reporting the
+// cancellation on the passed channel.
+func blockingFetcher(cancelled chan<- struct{}) block.Fetcher {
+	return fetcherFunc(func(ctx context.Context, link ipld.Link) (block.Block, error) {
+		<-ctx.Done()
+		cancelled <- struct{}{}
+		return nil, ctx.Err()
+	})
+}
+
+func failingFetcher(err error) block.Fetcher {
+	return fetcherFunc(func(ctx context.Context, link ipld.Link) (block.Block, error) {
+		return nil, err
+	})
+}
+
+func TestRaceFetcher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns first block and cancels the rest", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		b := randomBlock(t)
+		err := bs.Put(ctx, b)
+		require.NoError(t, err)
+
+		cancelled := make(chan struct{}, 2)
+		rf := block.NewRaceFetcher(
+			blockingFetcher(cancelled),
+			failingFetcher(errors.New("connection refused")),
+			bs,
+			blockingFetcher(cancelled),
+		)
+		g, err := rf.Get(ctx, b.Link())
+		require.NoError(t, err)
+		require.Equal(t, b.Bytes(), g.Bytes())
+
+		for range 2 {
+			select {
+			case <-cancelled:
+			case <-time.After(time.Second):
+				require.Fail(t, "fetcher was not cancelled")
+			}
+		}
+	})
+
+	t.Run("not found by any fetcher", func(t *testing.T) {
+		rf := block.NewRaceFetcher(block.NewMapBlockstore(), block.NewMapBlockstore())
+		_, err := rf.Get(ctx, randomBlock(t).Link())
+		require.ErrorIs(t, err, block.ErrNotFound)
+		require.ErrorContains(t, err, "fetcher 0: ")
+		require.ErrorContains(t, err, "fetcher 1: ")
+	})
+
+	t.Run("transport errors", func(t *testing.T) {
+		refused := errors.New("connection refused")
+		timeout := errors.New("timeout")
+		rf := block.NewRaceFetcher(
+			block.NewMapBlockstore(),
+			failingFetcher(refused),
+			failingFetcher(timeout),
+		)
+		_, err := rf.Get(ctx, randomBlock(t).Link())
+		require.ErrorIs(t, err, refused)
+		require.ErrorIs(t, err, timeout)
+		require.NotErrorIs(t, err, block.ErrNotFound)
+		// the not found error is still reported
+		require.ErrorContains(t, err, "fetcher 0: "+block.ErrNotFound.Error())
+	})
+
+	t.Run("no fetchers", func(t *testing.T) {
+		_, err := block.NewRaceFetcher().Get(ctx, randomBlock(t).Link())
+		require.ErrorIs(t, err, block.ErrNotFound)
+	})
+}
@@ -26,6 +26,11 @@ func (mbf *TieredBlockFetcher) Get(ctx context.Context, link ipld.Link) (Block,
 	return nil, ferr
 }
 
+// Unwrap returns the fetchers of the tiers, in order.
+func (mbf *TieredBlockFetcher) Unwrap() []Fetcher {
+	return mbf.fetchers
+}
+
 // NewTieredBlockFetcher cretaes a new [TieredBlockFetcher] - a [Fetcher] that
 // attempts to retrieve a block serially from multiple configured fetchers in
 // order, returning the first [Block] that is returned by a fetcher.
@@ -0,0 +1,348 @@
+package pail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/multiformats/go-varint"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/clock/event"
+	"github.com/storacha/go-pail/ipld/node"
+	"github.com/storacha/go-pail/shard"
+)
+
+// maxSectionSize is the maximum size of a CAR section (a CID and the block
+// data). Import rejects larger sections to avoid allocating unbounded memory
+// for a corrupt or malicious stream, so export refuses to write them.
+const maxSectionSize = 8 << 20
+
+var (
+	ErrInvalidCAR = errors.New("invalid CAR")
+	// ErrSectionTooLarge is returned when exporting or importing a CAR section
+	// (the header, or a CID and block data) larger than 8 MiB.
+	ErrSectionTooLarge = errors.New("CAR section exceeds maximum size")
+)
+
+type ExportOption func(*exportOptions)
+
+type exportOptions struct {
+	head   []ipld.Link
+	values block.Fetcher
+}
+
+// WithExportHead includes the clock events reachable from the passed head of
+// a merkle clock, for example the head of a [crdt] pail. The head events are
+// added to the roots of the CAR after the root shard.
+func WithExportHead(head []ipld.Link) ExportOption {
+	return func(o *exportOptions) {
+		o.head = head
+	}
+}
+
+// WithExportValues includes the blocks of the values in the pail, fetched from
+// the passed fetcher. Inline values are not fetched, since their data is
+// contained in the link.
+func WithExportValues(values block.Fetcher) ExportOption {
+	return func(o *exportOptions) {
+		o.values = values
+	}
+}
+
+// ExportCAR writes every shard reachable from the root into w as a CARv1
+// stream, with the root as the first root of the CAR. Blocks are written as
+// they are fetched, so the pail does not need to fit in memory. It fails with
+// [ErrSectionTooLarge] if a block could not be imported by [ImportCAR].
+func ExportCAR(ctx context.Context, blocks block.Fetcher, root ipld.Link, w io.Writer, opts ...ExportOption) error {
+	o := exportOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	shards := shard.NewFetcher(blocks)
+	rshard, err := shards.GetRoot(ctx, root)
+	if err != nil {
+		return fmt.Errorf("getting root: %w", err)
+	}
+
+	roots := append([]ipld.Link{root}, o.head...)
+	err = writeCARHeader(w, roots)
+	if err != nil {
+		return err
+	}
+
+	written := map[string]struct{}{}
+	write := func(b block.Block) error {
+		if _, ok := written[b.Link().String()]; ok {
+			return nil
+		}
+		written[b.Link().String()] = struct{}{}
+		return writeCARBlock(w, b)
+	}
+
+	var visit func(s shard.BlockView) error
+	visit = func(s shard.BlockView) error {
+		err := write(s)
+		if err != nil {
+			return err
+		}
+		for _, e := range s.Value().Entries() {
+			if v := e.Value().Value(); v != nil && o.values != nil && !shard.IsInline(v) {
+				if _, ok := written[v.String()]; !ok {
+					b, err := o.values.Get(ctx, v)
+					if err != nil {
+						return fmt.Errorf("getting value %s: %w", v.String(), err)
+					}
+					err = write(b)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			if l := e.Value().Shard(); l != nil {
+				child, err := shards.Get(ctx, l)
+				if err != nil {
+					return fmt.Errorf("getting shard %s: %w", l.String(), err)
+				}
+				err = visit(child)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	err = visit(shard.AsBlock(rshard))
+	if err != nil {
+		return err
+	}
+
+	// event data is not needed to find the parents, so it is not bound
+	events := event.NewFetcher(blocks, node.BinderFunc[ipld.Node](func(n ipld.Node) (ipld.Node, error) {
+		return n, nil
+	}))
+	queue := append([]ipld.Link{}, o.head...)
+	for len(queue) > 0 {
+		l := queue[0]
+		queue = queue[1:]
+		if _, ok := written[l.String()]; ok {
+			continue
+		}
+		e, err := events.Get(ctx, l)
+		if err != nil {
+			return fmt.Errorf("getting event %s: %w", l.String(), err)
+		}
+		err = write(e)
+		if err != nil {
+			return err
+		}
+		queue = append(queue, e.Value().Parents()...)
+	}
+	return nil
+}
+
+// ImportCAR reads a CARv1 stream, checks that every block hashes to its CID,
+// puts the blocks to the store and returns the roots of the CAR. Blocks are put
+// as they are read, so if an error is returned some blocks may have been
+// stored.
+func ImportCAR(ctx context.Context, r io.Reader, store block.Putter) ([]ipld.Link, error) {
+	br := bufio.NewReader(r)
+	roots, err := readCARHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		b, err := readCARBlock(br)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		err = checkHash(b)
+		if err != nil {
+			return nil, fmt.Errorf("%w: block %s: %w", ErrInvalidCAR, b.Link().String(), err)
+		}
+		err = store.Put(ctx, b)
+		if err != nil {
+			return nil, fmt.Errorf("putting block %s: %w", b.Link().String(), err)
+		}
+	}
+	return roots, nil
+}
+
+func writeCARHeader(w io.Writer, roots []ipld.Link) error {
+	np := basicnode.Prototype.Map
+	nb := np.NewBuilder()
+	ma, err := nb.BeginMap(2)
+	if err != nil {
+		return fmt.Errorf("beginning map: %w", err)
+	}
+	err = ma.AssembleKey().AssignString("roots")
+	if err != nil {
+		return fmt.Errorf("assembling roots key: %w", err)
+	}
+	la, err := ma.AssembleValue().BeginList(int64(len(roots)))
+	if err != nil {
+		return fmt.Errorf("beginning roots list: %w", err)
+	}
+	for _, r := range roots {
+		err = la.AssembleValue().AssignLink(r)
+		if err != nil {
+			return fmt.Errorf("assembling root value: %w", err)
+		}
+	}
+	err = la.Finish()
+	if err != nil {
+		return fmt.Errorf("finishing roots list: %w", err)
+	}
+	err = ma.AssembleKey().AssignString("version")
+	if err != nil {
+		return fmt.Errorf("assembling version key: %w", err)
+	}
+	err = ma.AssembleValue().AssignInt(1)
+	if err != nil {
+		return fmt.Errorf("assembling version value: %w", err)
+	}
+	err = ma.Finish()
+	if err != nil {
+		return fmt.Errorf("finishing map: %w", err)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	err = dagcbor.Encode(nb.Build(), buf)
+	if err != nil {
+		return fmt.Errorf("CBOR encoding header: %w", err)
+	}
+	if buf.Len() > maxSectionSize {
+		return fmt.Errorf("%w: header is %d bytes, maximum is %d", ErrSectionTooLarge, buf.Len(), maxSectionSize)
+	}
+	_, err = w.Write(varint.ToUvarint(uint64(buf.Len())))
+	if err == nil {
+		_, err = w.Write(buf.Bytes())
+	}
+	if err != nil {
+		return fmt.Errorf("writing header: %w", err)
+	}
+	return nil
+}
+
+func writeCARBlock(w io.Writer, b block.Block) error {
+	cl, ok := b.Link().(cidlink.Link)
+	if !ok {
+		return fmt.Errorf("unsupported link type: %T", b.Link())
+	}
+	c := cl.Cid.Bytes()
+	size := len(c) + len(b.Bytes())
+	if size > maxSectionSize {
+		return fmt.Errorf("%w: block %s is %d bytes, maximum is %d", ErrSectionTooLarge, b.Link().String(), size, maxSectionSize)
+	}
+	_, err := w.Write(varint.ToUvarint(uint64(size)))
+	if err == nil {
+		_, err = w.Write(c)
+	}
+	if err == nil {
+		_, err = w.Write(b.Bytes())
+	}
+	if err != nil {
+		return fmt.Errorf("writing block %s: %w", b.Link().String(), err)
+	}
+	return nil
+}
+
+// readSection reads a varint length prefixed section. It returns [io.EOF] if
+// the stream ends before the section starts.
+func readSection(r *bufio.Reader) ([]byte, error) {
+	size, err := varint.ReadUvarint(r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: reading section size: %w", ErrInvalidCAR, err)
+	}
+	if size > maxSectionSize {
+		return nil, fmt.Errorf("%w: %w: section is %d bytes, maximum is %d", ErrInvalidCAR, ErrSectionTooLarge, size, maxSectionSize)
+	}
+	buf := make([]byte, size)
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading section: %w", ErrInvalidCAR, err)
+	}
+	return buf, nil
+}
+
+func readCARHeader(r *bufio.Reader) ([]ipld.Link, error) {
+	b, err := readSection(r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: missing header", ErrInvalidCAR)
+		}
+		return nil, err
+	}
+
+	nb := basicnode.Prototype.Map.NewBuilder()
+	err = dagcbor.Decode(nb, bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding header: %w", ErrInvalidCAR, err)
+	}
+	n := nb.Build()
+
+	vn, err := n.LookupByString("version")
+	if err != nil {
+		return nil, fmt.Errorf("%w: looking up version: %w", ErrInvalidCAR, err)
+	}
+	version, err := vn.AsInt()
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding version: %w", ErrInvalidCAR, err)
+	}
+	if version != 1 {
+		return nil, fmt.Errorf("%w: unsupported version: %d", ErrInvalidCAR, version)
+	}
+
+	rn, err := n.LookupByString("roots")
+	if err != nil {
+		return nil, fmt.Errorf("%w: looking up roots: %w", ErrInvalidCAR, err)
+	}
+	if rn.Kind() != datamodel.Kind_List {
+		return nil, fmt.Errorf("%w: roots is not a list", ErrInvalidCAR)
+	}
+	var roots []ipld.Link
+	it := rn.ListIterator()
+	for !it.Done() {
+		_, ln, err := it.Next()
+		if err != nil {
+			return nil, fmt.Errorf("%w: iterating roots: %w", ErrInvalidCAR, err)
+		}
+		l, err := ln.AsLink()
+		if err != nil {
+			return nil, fmt.Errorf("%w: decoding root: %w", ErrInvalidCAR, err)
+		}
+		roots = append(roots, l)
+	}
+	return roots, nil
+}
+
+func readCARBlock(r *bufio.Reader) (block.Block, error) {
+	b, err := readSection(r)
+	if err != nil {
+		return nil, err
+	}
+	n, c, err := cid.CidFromBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding CID: %w", ErrInvalidCAR, err)
+	}
+	return block.New(cidlink.Link{Cid: c}, b[n:]), nil
+}
@@ -0,0 +1,199 @@
+package pail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/multiformats/go-varint"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/clock/event"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCAR(t *testing.T) {
+	ctx := context.Background()
+
+	// setup creates a pail in a new blockstore, returning the root and the
+	// blocks of the values, which are stored separately.
+	setup := func(t *testing.T) (*block.MapBlockstore, ipld.Link, *block.MapBlockstore) {
+		rb0, err := New()
+		require.NoError(t, err)
+
+		bs := block.NewMapBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		values := block.NewMapBlockstore()
+		var objects []object
+		for i := range 50 {
+			data := testutil.RandomBytes(t, 32)
+			digest, err := multihash.Sum(data, multihash.SHA2_256, -1)
+			require.NoError(t, err)
+			v := block.New(cidlink.Link{Cid: cid.NewCidV1(cid.Raw, digest)}, data)
+			err = values.Put(ctx, v)
+			require.NoError(t, err)
+			objects = append(objects, object{fmt.Sprintf("dir%d/file%03d.txt", i%5, i), v.Link()})
+		}
+		return bs, putAll(t, bs, rb0.Link(), objects), values
+	}
+
+	count := func(t *testing.T, bs *block.MapBlockstore) int {
+		n := 0
+		for _, err := range bs.Entries(ctx) {
+			require.NoError(t, err)
+			n++
+		}
+		return n
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		bs, root, _ := setup(t)
+
+		buf := bytes.NewBuffer([]byte{})
+		err := ExportCAR(ctx, bs, root, buf)
+		require.NoError(t, err)
+
+		imported := block.NewMapBlockstore()
+		roots, err := ImportCAR(ctx, buf, imported)
+		require.NoError(t, err)
+		require.Equal(t, []string{root.String()}, linkStrings(roots))
+
+		stats, err := Stats(ctx, bs, root)
+		require.NoError(t, err)
+		require.Equal(t, stats.Shards, count(t, imported))
+
+		problems, err := Verify(ctx, imported, root)
+		require.NoError(t, err)
+		require.Empty(t, problems)
+		require.Equal(t, collectEntries(t, bs, root), collectEntries(t, imported, root))
+	})
+
+	t.Run("values", func(t *testing.T) {
+		bs, root, values := setup(t)
+
+		buf := bytes.NewBuffer([]byte{})
+		err := ExportCAR(ctx, bs, root, buf, WithExportValues(values))
+		require.NoError(t, err)
+
+		imported := block.NewMapBlockstore()
+		_, err = ImportCAR(ctx, buf, imported)
+		require.NoError(t, err)
+
+		stats, err := Stats(ctx, bs, root)
+		require.NoError(t, err)
+		require.Equal(t, stats.Shards+count(t, values), count(t, imported))
+		for v, err := range values.Entries(ctx) {
+			require.NoError(t, err)
+			ok, err := imported.Has(ctx, v.Link())
+			require.NoError(t, err)
+			require.True(t, ok)
+		}
+	})
+
+	t.Run("clock events", func(t *testing.T) {
+		bs, root, _ := setup(t)
+
+		binder := testutil.NewTestEventBinder(t)
+		put := func(parents ...ipld.Link) ipld.Link {
+			e, err := event.MarshalBlock(event.NewEvent(testutil.RandomEventData(t), parents), binder)
+			require.NoError(t, err)
+			err = bs.Put(ctx, e)
+			require.NoError(t, err)
+			return e.Link()
+		}
+		e0 := put()
+		e1 := put(e0)
+		e2 := put(e0)
+		e3 := put(e1, e2)
+		e4 := put(e2)
+		head := []ipld.Link{e3, e4}
+
+		buf := bytes.NewBuffer([]byte{})
+		err := ExportCAR(ctx, bs, root, buf, WithExportHead(head))
+		require.NoError(t, err)
+
+		imported := block.NewMapBlockstore()
+		roots, err := ImportCAR(ctx, buf, imported)
+		require.NoError(t, err)
+		require.Equal(t, linkStrings([]ipld.Link{root, e3, e4}), linkStrings(roots))
+
+		stats, err := Stats(ctx, bs, root)
+		require.NoError(t, err)
+		require.Equal(t, stats.Shards+5, count(t, imported))
+		for _, e := range []ipld.Link{e0, e1, e2, e3, e4} {
+			ok, err := imported.Has(ctx, e)
+			require.NoError(t, err)
+			require.True(t, ok)
+		}
+	})
+
+	t.Run("block does not match CID", func(t *testing.T) {
+		bs, root, _ := setup(t)
+
+		buf := bytes.NewBuffer([]byte{})
+		err := ExportCAR(ctx, bs, root, buf)
+		require.NoError(t, err)
+
+		car := buf.Bytes()
+		car[len(car)-1] ^= 0xff
+		_, err = ImportCAR(ctx, bytes.NewReader(car), block.NewMapBlockstore())
+		require.ErrorIs(t, err, ErrInvalidCAR)
+		require.ErrorIs(t, err, ErrHashMismatch)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		bs, root, _ := setup(t)
+
+		buf := bytes.NewBuffer([]byte{})
+		err := ExportCAR(ctx, bs, root, buf)
+		require.NoError(t, err)
+
+		car := buf.Bytes()
+		_, err = ImportCAR(ctx, bytes.NewReader(car[:len(car)-1]), block.NewMapBlockstore())
+		require.ErrorIs(t, err, ErrInvalidCAR)
+
+		_, err = ImportCAR(ctx, bytes.NewReader(nil), block.NewMapBlockstore())
+		require.ErrorIs(t, err, ErrInvalidCAR)
+	})
+
+	t.Run("section too large", func(t *testing.T) {
+		bs, root, values := setup(t)
+
+		data := make([]byte, maxSectionSize)
+		digest, err := multihash.Sum(data, multihash.SHA2_256, -1)
+		require.NoError(t, err)
+		large := block.New(cidlink.Link{Cid: cid.NewCidV1(cid.Raw, digest)}, data)
+		err = values.Put(ctx, large)
+		require.NoError(t, err)
+		root, diff, err := Put(ctx, bs, root, "large", large.Link())
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+
+		err = ExportCAR(ctx, bs, root, bytes.NewBuffer([]byte{}), WithExportValues(values))
+		require.ErrorIs(t, err, ErrSectionTooLarge)
+
+		// a section size larger than the maximum, with no data following
+		header := []byte{0xa2, 0x65, 'r', 'o', 'o', 't', 's', 0x80, 0x67, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x01}
+		car := append([]byte{byte(len(header))}, header...)
+		car = append(car, varint.ToUvarint(maxSectionSize+1)...)
+		_, err = ImportCAR(ctx, bytes.NewReader(car), block.NewMapBlockstore())
+		require.ErrorIs(t, err, ErrInvalidCAR)
+		require.ErrorIs(t, err, ErrSectionTooLarge)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		// {"roots": [], "version": 2}
+		header := []byte{0xa2, 0x65, 'r', 'o', 'o', 't', 's', 0x80, 0x67, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x02}
+		car := append([]byte{byte(len(header))}, header...)
+		_, err := ImportCAR(ctx, bytes.NewReader(car), block.NewMapBlockstore())
+		require.ErrorIs(t, err, ErrInvalidCAR)
+		require.ErrorContains(t, err, "unsupported version: 2")
+	})
+}
@@ -15,8 +15,11 @@ import (
 )
 
 // Advance the clock by adding an event.
-func Advance[T any](ctx context.Context, blocks block.Fetcher, dataBinder node.Binder[T], head []ipld.Link, evt ipld.Link) ([]ipld.Link, error) {
-	events := event.NewFetcher(blocks, dataBinder)
+//
+// Options are used to decode events (see [event.NewFetcher]). Events are only
+// cached if a cache key is passed with [event.WithCacheKey].
+func Advance[T any](ctx context.Context, blocks block.Fetcher, dataBinder node.Binder[T], head []ipld.Link, evt ipld.Link, opts ...event.UnmarshalOption) ([]ipld.Link, error) {
+	events := event.NewFetcher(blocks, dataBinder, opts...)
 	headmap := map[ipld.Link]struct{}{}
 	for _, h := range head {
 		headmap[h] = struct{}{}
@@ -118,8 +121,10 @@ func contains[T any](ctx context.Context, events *event.Fetcher[T], a, b ipld.Li
 	return false, nil
 }
 
-func Visualize[T any](ctx context.Context, blocks block.Fetcher, dataBinder node.Binder[T], head []ipld.Link) iter.Seq2[string, error] {
-	events := event.NewFetcher(blocks, dataBinder)
+// Visualize yields the lines of a Graphviz DOT graph of the clock. Options are
+// used to decode events, as for [Advance].
+func Visualize[T any](ctx context.Context, blocks block.Fetcher, dataBinder node.Binder[T], head []ipld.Link, opts ...event.UnmarshalOption) iter.Seq2[string, error] {
+	events := event.NewFetcher(blocks, dataBinder, opts...)
 	return func(yield func(string, error) bool) {
 		if !yield("digraph clock {", nil) {
 			return
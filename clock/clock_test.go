@@ -6,8 +6,10 @@ import (
 	"testing"
 
 	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
 	"github.com/storacha/go-pail/clock/event"
 	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/ipld/node"
 	"github.com/stretchr/testify/require"
 )
 
@@ -350,6 +352,43 @@ func TestAdvance(t *testing.T) {
 		require.Contains(t, head, b7.Link())
 	})
 
+	t.Run("cache decoded events", func(t *testing.T) {
+		bs := testutil.NewBlockstore()
+		cf := block.NewCachingFetcher(bs, 100)
+
+		var decodes int
+		binder := node.BinderFunc[string](func(n ipld.Node) (string, error) {
+			decodes++
+			return stringBinder.Bind(n)
+		})
+
+		e0 := event.NewEvent("e0", nil)
+		b0, err := event.MarshalBlock(e0, stringBinder)
+		require.NoError(t, err)
+
+		e1 := event.NewEvent("e1", []ipld.Link{b0.Link()})
+		b1, err := event.MarshalBlock(e1, stringBinder)
+		require.NoError(t, err)
+
+		err = bs.PutAll(ctx, b0, b1)
+		require.NoError(t, err)
+
+		// events are only cached with a cache key
+		head := []ipld.Link{b0.Link()}
+		_, err = Advance(ctx, cf, binder, head, b1.Link())
+		require.NoError(t, err)
+		_, err = Advance(ctx, cf, binder, head, b1.Link())
+		require.NoError(t, err)
+		require.Equal(t, 4, decodes)
+
+		decodes = 0
+		_, err = Advance(ctx, cf, binder, head, b1.Link(), event.WithCacheKey("test"))
+		require.NoError(t, err)
+		_, err = Advance(ctx, cf, binder, head, b1.Link(), event.WithCacheKey("test"))
+		require.NoError(t, err)
+		require.Equal(t, 2, decodes)
+	})
+
 	t.Run("add an event with missing parents", func(t *testing.T) {
 		bs := testutil.NewBlockstore()
 
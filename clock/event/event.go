@@ -8,6 +8,7 @@ import (
 	"github.com/ipfs/go-cid"
 	"github.com/ipld/go-ipld-prime"
 	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/ipld/go-ipld-prime/node/basicnode"
 	"github.com/multiformats/go-multihash"
@@ -33,9 +34,50 @@ func NewEvent[T any](data T, parents []ipld.Link) Event[T] {
 	return event[T]{parents, data}
 }
 
+// Errors returned by [Unmarshal] when decoding an event that is not valid in
+// strict mode (see [WithStrict]).
+var (
+	ErrNotCanonical = errors.New("not canonically encoded")
+	ErrUnknownField = errors.New("unknown field")
+)
+
+// UnmarshalOption configures decoding by [Unmarshal].
+type UnmarshalOption func(*unmarshalOptions)
+
+type unmarshalOptions struct {
+	strict   bool
+	cacheKey string
+}
+
+// WithStrict enables strict decoding, which rejects events that are not
+// canonically encoded DAG-CBOR ([ErrNotCanonical]) or that have fields other
+// than parents and data ([ErrUnknownField]). This is intended for blocks
+// received from untrusted peers. The data is checked by the data binder.
+func WithStrict() UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.strict = true
+	}
+}
+
+// WithCacheKey sets the key that a [Fetcher] caches the events it decodes
+// under, in the [block.ViewCache] of the blocks it fetches from. The key must
+// identify how the event data is bound, so fetchers with data binders that
+// bind differently, for example a lenient and a strict binder, must use
+// different keys. Fetchers without a cache key do not cache decoded events. It
+// has no effect on [Unmarshal].
+func WithCacheKey(key string) UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.cacheKey = key
+	}
+}
+
 // Unmarshal deserializes CBOR encoded bytes to an [Event].
-func Unmarshal[T any](b []byte, dataBinder node.Binder[T]) (Event[T], error) {
+func Unmarshal[T any](b []byte, dataBinder node.Binder[T], opts ...UnmarshalOption) (Event[T], error) {
 	var e event[T]
+	o := unmarshalOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
 
 	np := basicnode.Prototype.Map
 	nb := np.NewBuilder()
@@ -45,6 +87,13 @@ func Unmarshal[T any](b []byte, dataBinder node.Binder[T]) (Event[T], error) {
 	}
 	n := nb.Build()
 
+	if o.strict {
+		err = checkStrict(n, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	pn, err := n.LookupByString("parents")
 	if err != nil {
 		return nil, fmt.Errorf("looking up parents: %w", err)
@@ -83,6 +132,38 @@ func Unmarshal[T any](b []byte, dataBinder node.Binder[T]) (Event[T], error) {
 	return e, nil
 }
 
+// checkStrict checks the event has only the expected fields and that the bytes
+// it was decoded from are the canonical encoding.
+func checkStrict(n datamodel.Node, b []byte) error {
+	fields := n.MapIterator()
+	if fields == nil {
+		return errors.New("event is not a map")
+	}
+	for !fields.Done() {
+		kn, _, err := fields.Next()
+		if err != nil {
+			return fmt.Errorf("iterating fields: %w", err)
+		}
+		k, err := kn.AsString()
+		if err != nil {
+			return fmt.Errorf("decoding field name as string: %w", err)
+		}
+		if k != "parents" && k != "data" {
+			return fmt.Errorf("%w: %q", ErrUnknownField, k)
+		}
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	err := dagcbor.Encode(n, buf)
+	if err != nil {
+		return fmt.Errorf("CBOR encoding: %w", err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		return ErrNotCanonical
+	}
+	return nil
+}
+
 // Marshal serializes an [Event] to CBOR encoded bytes.
 func Marshal[T any](event Event[T], dataUnbinder node.Unbinder[T]) ([]byte, error) {
 	np := basicnode.Prototype.Any
@@ -1,9 +1,17 @@
 package event
 
 import (
+	"bytes"
+	"context"
 	"testing"
 
 	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/storacha/go-pail/block"
 	"github.com/storacha/go-pail/internal/testutil"
 	"github.com/stretchr/testify/require"
 )
@@ -43,3 +51,63 @@ func TestMarshalUnmarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalStrict(t *testing.T) {
+	parent := testutil.RandomLink(t)
+
+	encode := func(t *testing.T, sort codec.MapSortMode, fn func(ma datamodel.MapAssembler)) []byte {
+		n, err := qp.BuildMap(basicnode.Prototype.Any, -1, fn)
+		require.NoError(t, err)
+		buf := bytes.NewBuffer([]byte{})
+		err = dagcbor.EncodeOptions{AllowLinks: true, MapSortMode: sort}.Encode(n, buf)
+		require.NoError(t, err)
+		return buf.Bytes()
+	}
+
+	t.Run("canonical event", func(t *testing.T) {
+		b, err := Marshal(NewEvent("test", []ipld.Link{parent}), testutil.NewStringBinder(t))
+		require.NoError(t, err)
+
+		e, err := Unmarshal(b, testutil.NewStringBinder(t), WithStrict())
+		require.NoError(t, err)
+		require.Equal(t, []ipld.Link{parent}, e.Parents())
+		require.Equal(t, "test", e.Data())
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		b := encode(t, codec.MapSortMode_RFC7049, func(ma datamodel.MapAssembler) {
+			qp.MapEntry(ma, "parents", qp.List(1, func(la datamodel.ListAssembler) {
+				qp.ListEntry(la, qp.Link(parent))
+			}))
+			qp.MapEntry(ma, "data", qp.String("test"))
+			qp.MapEntry(ma, "extra", qp.Int(1))
+		})
+
+		_, err := Unmarshal(b, testutil.NewStringBinder(t))
+		require.NoError(t, err)
+		_, err = Unmarshal(b, testutil.NewStringBinder(t), WithStrict())
+		require.ErrorIs(t, err, ErrUnknownField)
+	})
+
+	t.Run("not canonical", func(t *testing.T) {
+		b := encode(t, codec.MapSortMode_None, func(ma datamodel.MapAssembler) {
+			// canonical order is shortest key first
+			qp.MapEntry(ma, "parents", qp.List(0, func(la datamodel.ListAssembler) {}))
+			qp.MapEntry(ma, "data", qp.String("test"))
+		})
+
+		_, err := Unmarshal(b, testutil.NewStringBinder(t))
+		require.NoError(t, err)
+		_, err = Unmarshal(b, testutil.NewStringBinder(t), WithStrict())
+		require.ErrorIs(t, err, ErrNotCanonical)
+
+		ctx := context.Background()
+		bs := testutil.NewBlockstore()
+		blk := block.New(testutil.RandomLink(t), b)
+		err = bs.Put(ctx, blk)
+		require.NoError(t, err)
+
+		_, err = NewFetcher(bs, testutil.NewStringBinder(t), WithStrict()).Get(ctx, blk.Link())
+		require.ErrorIs(t, err, ErrNotCanonical)
+	})
+}
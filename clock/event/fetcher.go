@@ -11,22 +11,52 @@ import (
 type Fetcher[T any] struct {
 	blocks     block.Fetcher
 	dataBinder node.Binder[T]
+	opts       []UnmarshalOption
+	cache      block.ViewCache
+	kind       string
 }
 
 func (f *Fetcher[T]) Get(ctx context.Context, link ipld.Link) (BlockView[T], error) {
+	if f.cache != nil {
+		if b, ok := f.cache.GetView(link, f.kind); ok {
+			if v, ok := b.(BlockView[T]); ok {
+				return v, nil
+			}
+		}
+	}
+
 	b, err := f.blocks.Get(ctx, link)
 	if err != nil {
 		return nil, err
 	}
-
-	s, err := Unmarshal(b.Bytes(), f.dataBinder)
+	s, err := Unmarshal(b.Bytes(), f.dataBinder, f.opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	return block.NewBlockView(link, b.Bytes(), s), nil
+	v := block.NewBlockView(link, b.Bytes(), s)
+	if f.cache != nil {
+		f.cache.AddView(f.kind, v)
+	}
+	return v, nil
 }
 
-func NewFetcher[T any](blocks block.Fetcher, dataBinder node.Binder[T]) *Fetcher[T] {
-	return &Fetcher[T]{blocks, dataBinder}
+// NewFetcher creates a fetcher that decodes events from the passed blocks.
+// Options are used when decoding, for example [WithStrict] to reject events
+// that are not canonical. If a cache key is passed with [WithCacheKey] and
+// blocks has a [block.ViewCache] (see [block.ViewCacheOf]), for example a
+// [block.CachingFetcher], decoded events are cached under the key so that they
+// are not decoded again. Strict fetchers do not use the cache.
+func NewFetcher[T any](blocks block.Fetcher, dataBinder node.Binder[T], opts ...UnmarshalOption) *Fetcher[T] {
+	f := &Fetcher[T]{blocks: blocks, dataBinder: dataBinder, opts: opts}
+	o := unmarshalOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// a cached view may not have been decoded strictly
+	if o.cacheKey != "" && !o.strict {
+		f.cache, _ = block.ViewCacheOf(blocks)
+		f.kind = "event/" + o.cacheKey
+	}
+	return f
 }
@@ -0,0 +1,94 @@
+//go:build conformance
+
+package pail
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance checks that the pails built by a sequence of operations
+// match the fixtures generated from the JavaScript implementation by
+// testdata/conformance/generate.mjs, which record the version they were
+// generated with. It is only built with the conformance build tag, as the
+// fixtures are not yet committed, see make conformance.
+func TestConformance(t *testing.T) {
+	ctx := context.Background()
+	fixtures := testutil.LoadFixtures[testutil.PailVector](t, "testdata/conformance/pail.json")
+	t.Logf("fixtures generated by %s", fixtures.Generator)
+
+	for _, v := range fixtures.Vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			require.Len(t, v.Roots, len(v.Operations))
+
+			rb, err := New()
+			require.NoError(t, err)
+
+			bs := testutil.NewBlockstore()
+			err = bs.Put(ctx, rb)
+			require.NoError(t, err)
+
+			root := rb.Link()
+			for i, op := range v.Operations {
+				var diff shard.Diff
+				switch op.Type {
+				case "put":
+					root, diff, err = Put(ctx, bs, root, op.Key, testutil.MustParseLink(op.Value))
+				case "del":
+					root, diff, err = Del(ctx, bs, root, op.Key)
+				default:
+					t.Fatalf("unknown operation type: %s", op.Type)
+				}
+				require.NoError(t, err)
+				require.Equal(t, v.Roots[i], root.String(), "root after operation %d", i)
+				testutil.ApplyDiff(t, diff, bs)
+			}
+
+			shards := collectShards(t, bs, root)
+			require.Len(t, shards, len(v.Shards))
+			for _, f := range v.Shards {
+				b, ok := shards[f.CID]
+				require.True(t, ok, "missing shard %s", f.CID)
+				require.Equal(t, f.Bytes, hex.EncodeToString(b.Bytes()))
+
+				// re-encoding the decoded shard produces the same bytes
+				var s shard.Shard
+				if f.CID == root.String() {
+					s, err = shard.UnmarshalRoot(b.Bytes())
+				} else {
+					s, err = shard.Unmarshal(b.Bytes())
+				}
+				require.NoError(t, err)
+				data, err := shard.Marshal(s)
+				require.NoError(t, err)
+				require.Equal(t, f.Bytes, hex.EncodeToString(data))
+			}
+		})
+	}
+}
+
+// collectShards returns the blocks of all the shards reachable from the root,
+// keyed by CID.
+func collectShards(t *testing.T, blocks block.Fetcher, root ipld.Link) map[string]shard.BlockView {
+	shards := map[string]shard.BlockView{}
+	var collect func(l ipld.Link)
+	collect = func(l ipld.Link) {
+		s, err := shard.NewFetcher(blocks).Get(context.Background(), l)
+		require.NoError(t, err)
+		shards[l.String()] = s
+		for _, e := range s.Value().Entries() {
+			if e.Value().Shard() != nil {
+				collect(e.Value().Shard())
+			}
+		}
+	}
+	collect(root)
+	return shards
+}
@@ -1,19 +1,193 @@
 package pail
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"iter"
+	"strings"
 
+	"github.com/ipld/go-ipld-prime"
 	"github.com/storacha/go-pail/block"
 	"github.com/storacha/go-pail/shard"
 )
 
 // New creates a new empty pail. It encodes and hashes the data and returns a
-// block view of the root shard.
-func New() (block.BlockView[shard.RootShard], error) {
-	rs := shard.NewRoot(nil)
+// block view of the root shard. Options may be passed to configure the root
+// shard, for example to allow UTF-8 keys of up to 1024 bytes:
+//
+//	pail.New(shard.WithKeyChars(shard.KeyCharsUTF8), shard.WithMaxKeySize(1024))
+//
+// The root shard parameters are retained when the pail is modified.
+func New(opts ...shard.RootOption) (block.BlockView[shard.RootShard], error) {
+	rs := shard.NewRoot(nil, opts...)
+	err := validateRoot(rs)
+	if err != nil {
+		return nil, err
+	}
 	rb, err := shard.MarshalBlock(rs)
 	if err != nil {
 		return nil, fmt.Errorf("marshalling pail root: %w", err)
 	}
 	return rb, nil
 }
+
+// FromEntries builds a new pail from entries sorted in ascending key order.
+// The shard tree is built bottom-up, and each shard block is passed to emit as
+// soon as it is complete - the root shard is always emitted last. The link to
+// the root shard is returned.
+//
+// The resulting pail is identical to one built by calling [Put] for each entry
+// on a pail created with [New] using the same options, unless the pail has a
+// maximum shard size. Shards are then split into parts once they are
+// complete, rather than as entries are added.
+func FromEntries(ctx context.Context, entries iter.Seq2[Entry, error], emit func(shard.BlockView) error, opts ...shard.RootOption) (ipld.Link, error) {
+	rshard := shard.NewRoot(nil, opts...)
+	err := validateRoot(rshard)
+	if err != nil {
+		return nil, err
+	}
+
+	// stack of shards that are still being built, each one a prefix of the
+	// current key, starting with the root shard.
+	stack := []*buildShard{{}}
+
+	// finish encodes the shard at the top of the stack and adds a link to it in
+	// the parent shard.
+	finish := func() error {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		parent := stack[len(stack)-1]
+
+		// split the shard if it is too large
+		links, blocks, err := encodeShard(rshard, s.prefix, false, s.entries)
+		if err != nil {
+			return err
+		}
+		link, fanouts, err := joinParts(rshard, s.prefix, links)
+		if err != nil {
+			return err
+		}
+		for _, b := range append(blocks, fanouts...) {
+			err = emit(b)
+			if err != nil {
+				return err
+			}
+		}
+
+		key := s.prefix[len(parent.prefix):]
+		parent.entries = append(parent.entries, shard.NewEntry(key, shard.NewValue(s.value, link)))
+		return nil
+	}
+
+	// add the entry to the tree, given the entry that preceded it and the entry
+	// that follows it, which determine the shard it belongs in.
+	add := func(prev, cur, next *Entry) error {
+		var common int
+		if prev != nil {
+			common = commonPrefixLen(rshard, prev.Key, cur.Key)
+		}
+		if next != nil {
+			common = max(common, commonPrefixLen(rshard, cur.Key, next.Key))
+		}
+
+		// the prefix of the shard the entry belongs in
+		var prefix string
+		if common < len(cur.Key) {
+			prefix = cur.Key[:common]
+		} else if cur.Key != "" {
+			// key is a prefix of the next key, so it's value is stored alongside
+			// the link to the shard that contains the next key.
+			chars := splitKey(rshard, cur.Key)
+			prefix = cur.Key[:len(cur.Key)-len(chars[len(chars)-1])]
+		}
+
+		for !strings.HasPrefix(prefix, stack[len(stack)-1].prefix) {
+			err := finish()
+			if err != nil {
+				return err
+			}
+		}
+		for len(stack[len(stack)-1].prefix) < len(prefix) {
+			p := stack[len(stack)-1].prefix
+			char := splitKey(rshard, prefix[len(p):])[0]
+			stack = append(stack, &buildShard{prefix: prefix[:len(p)+len(char)]})
+		}
+
+		if next != nil && cur.Key != "" && strings.HasPrefix(next.Key, cur.Key) {
+			stack = append(stack, &buildShard{prefix: cur.Key, value: cur.Value})
+			return nil
+		}
+
+		s := stack[len(stack)-1]
+		s.entries = append(s.entries, shard.NewEntry(cur.Key[len(prefix):], shard.NewValue(cur.Value, nil)))
+		return nil
+	}
+
+	var prev, cur *Entry
+	for e, err := range entries {
+		if err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		err = validateKey(rshard, e.Key)
+		if err != nil {
+			return nil, err
+		}
+		if cur != nil && e.Key <= cur.Key {
+			return nil, fmt.Errorf("entries not in ascending key order: %q follows %q", e.Key, cur.Key)
+		}
+		if e.Value == nil {
+			return nil, errors.New("missing value for entry")
+		}
+		err = validateValue(rshard, e.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		next := e
+		if cur != nil {
+			err = add(prev, cur, &next)
+			if err != nil {
+				return nil, err
+			}
+		}
+		prev, cur = cur, &next
+	}
+	if cur != nil {
+		err := add(prev, cur, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for len(stack) > 1 {
+		err := finish()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	blocks, err := encodeRoot(rshard, false, stack[0].entries)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling pail root: %w", err)
+	}
+	for _, b := range blocks {
+		err = emit(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return blocks[len(blocks)-1].Link(), nil
+}
+
+// buildShard is a shard that is being built by [FromEntries].
+type buildShard struct {
+	prefix  string
+	entries []shard.Entry
+	// value is the value for the key equal to the shard prefix, stored in the
+	// parent shard alongside the link to this shard.
+	value ipld.Link
+}
@@ -0,0 +1,114 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"testing"
+
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromEntries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("same root as sequential puts", func(t *testing.T) {
+		objects := []object{
+			{"a", testutil.RandomLink(t)},
+			{"aa", testutil.RandomLink(t)},
+			{"aaaa", testutil.RandomLink(t)},
+			{"aaaaA", testutil.RandomLink(t)},
+			{"aaab", testutil.RandomLink(t)},
+			{"aabb", testutil.RandomLink(t)},
+			{"abbb", testutil.RandomLink(t)},
+			{"b", testutil.RandomLink(t)},
+			{"bbbb", testutil.RandomLink(t)},
+			{"cats/ginger", testutil.RandomLink(t)},
+			{"cats/tabby", testutil.RandomLink(t)},
+			{"dogs", testutil.RandomLink(t)},
+		}
+		for i := range 200 {
+			objects = append(objects, object{fmt.Sprintf("file/%d.txt", i), testutil.RandomLink(t)})
+		}
+		slices.SortFunc(objects, objectKeySort)
+
+		rb0, err := New()
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		expect := putAll(t, bs, rb0.Link(), objects)
+
+		var blocks []shard.BlockView
+		root, err := FromEntries(ctx, objectEntries(objects), func(b shard.BlockView) error {
+			blocks = append(blocks, b)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, expect.String(), root.String())
+
+		// root is emitted last
+		require.Equal(t, root.String(), blocks[len(blocks)-1].Link().String())
+		require.ElementsMatch(t, reachable(t, bs, expect), links(blocks))
+	})
+
+	t.Run("no entries", func(t *testing.T) {
+		rb0, err := New()
+		require.NoError(t, err)
+
+		var blocks []shard.BlockView
+		root, err := FromEntries(ctx, objectEntries(nil), func(b shard.BlockView) error {
+			blocks = append(blocks, b)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, rb0.Link().String(), root.String())
+		require.Len(t, blocks, 1)
+	})
+
+	t.Run("unsorted entries", func(t *testing.T) {
+		objects := []object{
+			{"b", testutil.RandomLink(t)},
+			{"a", testutil.RandomLink(t)},
+		}
+		_, err := FromEntries(ctx, objectEntries(objects), func(b shard.BlockView) error {
+			return nil
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("duplicate entries", func(t *testing.T) {
+		objects := []object{
+			{"a", testutil.RandomLink(t)},
+			{"a", testutil.RandomLink(t)},
+		}
+		_, err := FromEntries(ctx, objectEntries(objects), func(b shard.BlockView) error {
+			return nil
+		})
+		require.Error(t, err)
+	})
+}
+
+func objectEntries(objects []object) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		for _, o := range objects {
+			if !yield(Entry{o.key, o.value}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// entriesOf returns the objects as a list of entries.
+func entriesOf(objects []object) []Entry {
+	var entries []Entry
+	for e := range objectEntries(objects) {
+		entries = append(entries, e)
+	}
+	return entries
+}
@@ -0,0 +1,55 @@
+//go:build conformance
+
+package crdt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance checks that the events and pails created by a sequence of
+// operations match the fixtures generated from the JavaScript implementation
+// by testdata/conformance/generate.mjs. It is only built with the conformance
+// build tag, as the fixtures are not yet committed, see make conformance.
+func TestConformance(t *testing.T) {
+	ctx := context.Background()
+	fixtures := testutil.LoadFixtures[testutil.CRDTVector](t, "../testdata/conformance/crdt.json")
+	t.Logf("fixtures generated by %s", fixtures.Generator)
+
+	for _, v := range fixtures.Vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			require.Len(t, v.Results, len(v.Operations))
+
+			tp := testPail{t: t, blocks: testutil.NewBlockstore()}
+			for i, op := range v.Operations {
+				var res Result
+				switch op.Type {
+				case "put":
+					res = tp.Put(ctx, op.Key, testutil.MustParseLink(op.Value))
+				case "del":
+					res = tp.Del(ctx, op.Key)
+				default:
+					t.Fatalf("unknown operation type: %s", op.Type)
+				}
+
+				expect := v.Results[i]
+				require.Equal(t, expect.Root, res.Root.String(), "root after operation %d", i)
+				require.Equal(t, expect.Head, linkStrings(res.Head), "head after operation %d", i)
+				require.NotNil(t, res.Event)
+				require.Equal(t, expect.Event, res.Event.Link().String(), "event for operation %d", i)
+			}
+		})
+	}
+}
+
+func linkStrings(links []ipld.Link) []string {
+	var strs []string
+	for _, l := range links {
+		strs = append(strs, l.String())
+	}
+	return strs
+}
@@ -23,16 +23,18 @@ var ErrEventNotFound = errors.New("event not found")
 
 // Put a value (a CID) for the given key. If the key exists it's value is
 // overwritten.
-func Put(ctx context.Context, blocks block.Fetcher, head []ipld.Link, key string, value ipld.Link) (Result, error) {
+//
+// If the head is empty a new pail is created, configured using the passed
+// options. The options are ignored otherwise, since the pail retains the
+// parameters it was created with.
+func Put(ctx context.Context, blocks block.Fetcher, head []ipld.Link, key string, value ipld.Link, opts ...shard.RootOption) (Result, error) {
 	mblocks := block.NewMapBlockstore()
 	blocks = block.NewTieredBlockFetcher(mblocks, blocks)
 
 	if len(head) == 0 {
-		rshard := shard.NewRoot(nil)
-
-		rblock, err := shard.MarshalBlock(rshard)
+		rblock, err := pail.New(opts...)
 		if err != nil {
-			return Result{}, fmt.Errorf("marshalling shard: %w", err)
+			return Result{}, fmt.Errorf("creating pail: %w", err)
 		}
 
 		_ = mblocks.Put(ctx, rblock)
@@ -48,7 +50,7 @@ func Put(ctx context.Context, blocks block.Fetcher, head []ipld.Link, key string
 			return Result{}, fmt.Errorf("marshalling event: %w", err)
 		}
 
-		head, err = clock.Advance(ctx, blocks, node.BinderFunc[operation.Operation](operation.Bind), head, eblock.Link())
+		head, err = clock.Advance(ctx, blocks, node.BinderFunc[operation.Operation](operation.Bind), head, eblock.Link(), event.WithCacheKey(operation.CacheKey))
 		if err != nil {
 			return Result{}, fmt.Errorf("advancing clock: %w", err)
 		}
@@ -99,7 +101,7 @@ func Put(ctx context.Context, blocks block.Fetcher, head []ipld.Link, key string
 
 	_ = mblocks.Put(ctx, eblock)
 
-	head, err = clock.Advance(ctx, blocks, node.BinderFunc[operation.Operation](operation.Bind), head, eblock.Link())
+	head, err = clock.Advance(ctx, blocks, node.BinderFunc[operation.Operation](operation.Bind), head, eblock.Link(), event.WithCacheKey(operation.CacheKey))
 	if err != nil {
 		return Result{}, fmt.Errorf("advancing clock: %w", err)
 	}
@@ -172,7 +174,7 @@ func Del(ctx context.Context, blocks block.Fetcher, head []ipld.Link, key string
 
 	_ = mblocks.Put(ctx, eblock)
 
-	head, err = clock.Advance(ctx, blocks, node.BinderFunc[operation.Operation](operation.Bind), head, eblock.Link())
+	head, err = clock.Advance(ctx, blocks, node.BinderFunc[operation.Operation](operation.Bind), head, eblock.Link(), event.WithCacheKey(operation.CacheKey))
 	if err != nil {
 		return Result{}, fmt.Errorf("advancing clock: %w", err)
 	}
@@ -262,7 +264,7 @@ func Root(ctx context.Context, blocks block.Fetcher, head []ipld.Link) (ipld.Lin
 
 	mblocks := block.NewMapBlockstore()
 	blocks = block.NewTieredBlockFetcher(mblocks, blocks)
-	events := event.NewFetcher(blocks, node.BinderFunc[operation.Operation](operation.Bind))
+	events := event.NewFetcher(blocks, node.BinderFunc[operation.Operation](operation.Bind), event.WithCacheKey(operation.CacheKey))
 
 	if len(head) == 1 {
 		event, err := events.Get(ctx, head[0])
@@ -9,6 +9,7 @@ import (
 
 	"github.com/ipld/go-ipld-prime"
 	"github.com/storacha/go-pail"
+	"github.com/storacha/go-pail/block"
 	"github.com/storacha/go-pail/clock"
 	"github.com/storacha/go-pail/crdt/operation"
 	"github.com/storacha/go-pail/internal/testutil"
@@ -38,6 +39,29 @@ func TestCRDTPut(t *testing.T) {
 		require.Equal(t, res.Event.Link(), res.Head[0])
 	})
 
+	t.Run("put a value to a new clock with root shard options", func(t *testing.T) {
+		bs := testutil.NewBlockstore()
+		opts := []shard.RootOption{shard.WithKeyChars(shard.KeyCharsUTF8), shard.WithMaxKeySize(16)}
+
+		res, err := Put(ctx, bs, nil, "日本", testutil.RandomLink(t), opts...)
+		require.NoError(t, err)
+		err = bs.Put(ctx, res.Event)
+		require.NoError(t, err)
+		for _, b := range res.Additions {
+			err = bs.Put(ctx, b)
+			require.NoError(t, err)
+		}
+
+		rshard, err := shard.NewFetcher(bs).GetRoot(ctx, res.Root)
+		require.NoError(t, err)
+		require.Equal(t, shard.KeyCharsUTF8, rshard.Value().KeyChars())
+		require.Equal(t, int64(16), rshard.Value().MaxKeySize())
+
+		// subsequent puts use the parameters of the existing pail
+		_, err = Put(ctx, bs, res.Head, "日本/日本/日本", testutil.RandomLink(t))
+		require.ErrorContains(t, err, "exceeds max size")
+	})
+
 	t.Run("linear put multiple values", func(t *testing.T) {
 		bs := testutil.NewBlockstore()
 		alice := testPail{t: t, blocks: bs}
@@ -327,6 +351,31 @@ func TestCRDTDel(t *testing.T) {
 	})
 }
 
+func TestCRDTCachingFetcher(t *testing.T) {
+	ctx := context.Background()
+	bs := testutil.NewBlockstore()
+	alice := testPail{t: t, blocks: bs}
+	for i := range 10 {
+		alice.Put(ctx, fmt.Sprintf("key%d", i), testutil.RandomLink(t))
+	}
+
+	// puts read the pail through a fetcher that wraps the caching fetcher
+	cf := block.NewCachingFetcher(bs, 1000)
+	val := testutil.RandomLink(t)
+	r0, err := Put(ctx, cf, alice.head, "key5", val)
+	require.NoError(t, err)
+	stats := cf.Stats()
+	// the decoded events and shards are cached with the blocks they were
+	// decoded from, which includes the new event decoded while advancing the
+	// clock
+	require.Equal(t, int(stats.Misses)+1, cf.Len())
+
+	r1, err := Put(ctx, cf, alice.head, "key5", val)
+	require.NoError(t, err)
+	require.Equal(t, r0.Root, r1.Root)
+	require.Equal(t, stats.Misses, cf.Stats().Misses)
+}
+
 type testPail struct {
 	t      *testing.T
 	blocks *testutil.MapBlockstore
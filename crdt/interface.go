@@ -19,3 +19,15 @@ type Result struct {
 	// an existing key or deleting a key that does not exist.
 	Event block.BlockView[event.Event[operation.Operation]]
 }
+
+// AddedBlocks returns the added shard blocks and the event block, if any, so
+// that a result can be passed to [block.Apply].
+func (r Result) AddedBlocks() []block.Block {
+	blocks := r.Diff.AddedBlocks()
+	if r.Event != nil {
+		blocks = append(blocks, r.Event)
+	}
+	return blocks
+}
+
+var _ block.Changes = Result{}
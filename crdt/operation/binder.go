@@ -1,8 +1,19 @@
 package operation
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+
 	"github.com/ipld/go-ipld-prime"
 	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/storacha/go-pail/shard"
+)
+
+// Errors returned by [BindStrict] when binding an operation that is not valid.
+var (
+	ErrUnknownType  = errors.New("unknown operation type")
+	ErrUnknownField = errors.New("unknown field")
 )
 
 func Unbind(op Operation) (ipld.Node, error) {
@@ -44,7 +55,7 @@ func Unbind(op Operation) (ipld.Node, error) {
 		if err != nil {
 			return nil, err
 		}
-		err = ma.AssembleValue().AssignLink(op.Value())
+		err = shard.AssignValue(ma.AssembleValue(), op.Value())
 		if err != nil {
 			return nil, err
 		}
@@ -58,6 +69,10 @@ func Unbind(op Operation) (ipld.Node, error) {
 	return nb.Build(), nil
 }
 
+// CacheKey is the key that events with operations bound by [Bind] are cached
+// under, see event.WithCacheKey.
+const CacheKey = "crdt/operation"
+
 func Bind(n ipld.Node) (Operation, error) {
 	op := operation{}
 
@@ -96,7 +111,7 @@ func Bind(n ipld.Node) (Operation, error) {
 		if err != nil {
 			return nil, err
 		}
-		v, err := vn.AsLink()
+		v, err := shard.DecodeValue(vn)
 		if err != nil {
 			return nil, err
 		}
@@ -105,3 +120,40 @@ func Bind(n ipld.Node) (Operation, error) {
 
 	return op, nil
 }
+
+// BindStrict binds an operation like [Bind], but rejects operations with an
+// unknown type or with fields other than those of the operation type. Use it
+// together with event.WithStrict to decode events received from untrusted
+// peers.
+func BindStrict(n ipld.Node) (Operation, error) {
+	op, err := Bind(n)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{"root", "type", "key"}
+	switch op.Type() {
+	case TypePut:
+		fields = append(fields, "value")
+	case TypeDel:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownType, op.Type())
+	}
+	if n.Length() != int64(len(fields)) {
+		it := n.MapIterator()
+		for !it.Done() {
+			kn, _, err := it.Next()
+			if err != nil {
+				return nil, err
+			}
+			k, err := kn.AsString()
+			if err != nil {
+				return nil, err
+			}
+			if !slices.Contains(fields, k) {
+				return nil, fmt.Errorf("%w: %q", ErrUnknownField, k)
+			}
+		}
+	}
+	return op, nil
+}
@@ -0,0 +1,74 @@
+package operation
+
+import (
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindStrict(t *testing.T) {
+	root := testutil.RandomLink(t)
+	value := testutil.RandomLink(t)
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, op := range []Operation{NewPut(root, "key", value), NewDel(root, "key")} {
+			n, err := Unbind(op)
+			require.NoError(t, err)
+			o, err := BindStrict(n)
+			require.NoError(t, err)
+			require.Equal(t, op, o)
+		}
+	})
+
+	t.Run("inline value", func(t *testing.T) {
+		inline, err := shard.InlineLink(basicnode.NewString("small"))
+		require.NoError(t, err)
+		op := NewPut(root, "key", inline)
+
+		n, err := Unbind(op)
+		require.NoError(t, err)
+		b, err := ipld.Encode(n, dagcbor.Encode)
+		require.NoError(t, err)
+		n, err = ipld.Decode(b, dagcbor.Decode)
+		require.NoError(t, err)
+
+		o, err := BindStrict(n)
+		require.NoError(t, err)
+		require.Equal(t, op, o)
+		require.True(t, shard.IsInline(o.Value()))
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		n, err := qp.BuildMap(basicnode.Prototype.Any, 4, func(ma datamodel.MapAssembler) {
+			qp.MapEntry(ma, "root", qp.Link(root))
+			qp.MapEntry(ma, "type", qp.String(TypeDel))
+			qp.MapEntry(ma, "key", qp.String("key"))
+			qp.MapEntry(ma, "value", qp.Link(value))
+		})
+		require.NoError(t, err)
+
+		_, err = Bind(n)
+		require.NoError(t, err)
+		_, err = BindStrict(n)
+		require.ErrorIs(t, err, ErrUnknownField)
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		n, err := qp.BuildMap(basicnode.Prototype.Any, 3, func(ma datamodel.MapAssembler) {
+			qp.MapEntry(ma, "root", qp.Link(root))
+			qp.MapEntry(ma, "type", qp.String("move"))
+			qp.MapEntry(ma, "key", qp.String("key"))
+		})
+		require.NoError(t, err)
+
+		_, err = BindStrict(n)
+		require.ErrorIs(t, err, ErrUnknownType)
+	})
+}
@@ -0,0 +1,105 @@
+package pail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+)
+
+var (
+	ErrInvalidCursor           = errors.New("invalid cursor")
+	ErrCursorRootMismatch      = errors.New("cursor does not apply to pail root")
+	ErrCursorDirectionMismatch = errors.New("cursor does not apply to iteration direction")
+)
+
+// Cursor creates an opaque pagination token that can be passed to [Entries]
+// using [WithCursor] to continue listing entries after the given key. The
+// cursor may only be used to list entries of the pail with the given root, in
+// the same direction. Pass the options used to list the entries, so that the
+// direction is recorded, e.g. [WithReverse]. Other options are ignored.
+func Cursor(root ipld.Link, key string, opts ...EntriesOption) (string, error) {
+	o := &entriesOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	nb := basicnode.Prototype.List.NewBuilder()
+	la, err := nb.BeginList(3)
+	if err != nil {
+		return "", fmt.Errorf("beginning cursor list: %w", err)
+	}
+	err = la.AssembleValue().AssignLink(root)
+	if err != nil {
+		return "", fmt.Errorf("assembling root: %w", err)
+	}
+	err = la.AssembleValue().AssignString(key)
+	if err != nil {
+		return "", fmt.Errorf("assembling key: %w", err)
+	}
+	err = la.AssembleValue().AssignBool(o.reverse)
+	if err != nil {
+		return "", fmt.Errorf("assembling direction: %w", err)
+	}
+	err = la.Finish()
+	if err != nil {
+		return "", fmt.Errorf("finishing cursor list: %w", err)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	err = dagcbor.Encode(nb.Build(), buf)
+	if err != nil {
+		return "", fmt.Errorf("CBOR encoding: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// parseCursor decodes a cursor created by [Cursor], returning the pail root it
+// applies to, the last key that was listed and whether entries were listed in
+// reverse.
+func parseCursor(cursor string) (ipld.Link, string, bool, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("%w: decoding base64: %w", ErrInvalidCursor, err)
+	}
+
+	nb := basicnode.Prototype.List.NewBuilder()
+	err = dagcbor.Decode(nb, bytes.NewReader(b))
+	if err != nil {
+		return nil, "", false, fmt.Errorf("%w: CBOR decoding: %w", ErrInvalidCursor, err)
+	}
+	n := nb.Build()
+
+	rn, err := n.LookupByIndex(0)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("%w: looking up root: %w", ErrInvalidCursor, err)
+	}
+	root, err := rn.AsLink()
+	if err != nil {
+		return nil, "", false, fmt.Errorf("%w: decoding root as link: %w", ErrInvalidCursor, err)
+	}
+
+	kn, err := n.LookupByIndex(1)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("%w: looking up key: %w", ErrInvalidCursor, err)
+	}
+	key, err := kn.AsString()
+	if err != nil {
+		return nil, "", false, fmt.Errorf("%w: decoding key as string: %w", ErrInvalidCursor, err)
+	}
+
+	dn, err := n.LookupByIndex(2)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("%w: looking up direction: %w", ErrInvalidCursor, err)
+	}
+	reverse, err := dn.AsBool()
+	if err != nil {
+		return nil, "", false, fmt.Errorf("%w: decoding direction as bool: %w", ErrInvalidCursor, err)
+	}
+
+	return root, key, reverse, nil
+}
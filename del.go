@@ -13,6 +13,9 @@ import (
 
 // Del deletes the value for the given key from the bucket. If the key is not
 // found, [ErrNotFound] is returned as the error value.
+//
+// In a pail with the [shard.KeyCharsBinary] key character set the key is a
+// string of raw bytes, see [DelBytes].
 func Del(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string) (ipld.Link, shard.Diff, error) {
 	shards := shard.NewFetcher(blocks)
 	rshard, err := shards.GetRoot(ctx, root)
@@ -40,35 +43,20 @@ func Del(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string)
 		return nil, shard.Diff{}, ErrNotFound
 	}
 
-	var additions []shard.BlockView
 	removals := path[:]
 
-	var nshard shard.Shard
+	var ents []shard.Entry
 	if entry.Value().Shard() != nil {
 		// remove the value from this link+value
-		ents := target.Value().Entries()[:]
+		ents = slices.Clone(target.Value().Entries())
 		ents[entryidx] = shard.NewEntry(entry.Key(), shard.NewValue(nil, entry.Value().Shard()))
-		if target.Value().Prefix() == "" {
-			nshard = shard.NewRoot(ents)
-		} else {
-			nshard = shard.New(target.Value().Prefix(), ents)
-		}
 	} else {
-		ents := slices.Delete(target.Value().Entries()[:], entryidx, entryidx+1)
-		if target.Value().Prefix() == "" {
-			nshard = shard.NewRoot(ents)
-		} else {
-			nshard = shard.New(target.Value().Prefix(), ents)
-		}
+		ents = slices.Delete(slices.Clone(target.Value().Entries()), entryidx, entryidx+1)
 
 		// if now empty, remove from parent
-		for i := len(path) - 1; i > 0; i-- {
-			if len(nshard.Entries()) > 0 {
-				break
-			}
-
-			child := path[i]
-			parent := path[i-1]
+		for len(ents) == 0 && len(path) > 1 {
+			child := path[len(path)-1]
+			parent := path[len(path)-2]
 
 			entidx := slices.IndexFunc(parent.Value().Entries(), func(e shard.Entry) bool {
 				return e.Value().Shard() != nil && e.Value().Shard().String() == child.Link().String()
@@ -79,54 +67,39 @@ func Del(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string)
 
 			// delete the parent entry, unless it has a value also, then just clear
 			// the shard link.
-			var ents []shard.Entry
 			if parent.Value().Entries()[entidx].Value().Value() != nil {
-				ents = parent.Value().Entries()[:]
+				ents = slices.Clone(parent.Value().Entries())
 				ents[entidx] = shard.NewEntry(ents[entidx].Key(), shard.NewValue(ents[entidx].Value().Value(), nil))
 			} else {
-				ents = slices.Delete(parent.Value().Entries()[:], entidx, 1)
+				ents = slices.Delete(slices.Clone(parent.Value().Entries()), entidx, entidx+1)
+				// the first part of a fanout shard has an empty key
+				if shard.IsFanout(parent.Value()) && entidx == 0 && len(ents) > 0 {
+					ents[0] = partEntry("", ents[0].Value().Shard())
+				}
 			}
-
-			nshard = shard.New(parent.Value().Prefix(), ents)
-			path = path[:i] // pop the parent from the path, they no longer exist
+			path = path[:len(path)-1] // pop the child from the path, it no longer exists
 		}
 	}
 
-	child, err := shard.MarshalBlock(nshard)
+	additions, err := propagate(rshard.Value(), path, ents)
 	if err != nil {
 		return nil, shard.Diff{}, err
 	}
-	additions = append(additions, child)
-
-	// path is root -> target, so work backwards, propagating the new shard CID
-	for i := len(path) - 2; i >= 0; i-- {
-		parent := path[i]
-		key := child.Value().Prefix()[len(parent.Value().Prefix()):]
-
-		entries := parent.Value().Entries()[:]
-		for i, e := range entries {
-			if e.Key() == key {
-				if e.Value().Shard() == nil {
-					return nil, shard.Diff{}, fmt.Errorf("\"%s\" is not a shard link in: %s", key, parent.Link().String())
-				}
-				entries[i] = shard.NewEntry(key, shard.NewValue(e.Value().Value(), child.Link()))
-				break
-			}
-		}
-
-		var cshard shard.Shard
-		if parent.Value().Prefix() == "" {
-			cshard = shard.NewRoot(entries)
-		} else {
-			cshard = shard.New(parent.Value().Prefix(), entries)
-		}
+	return additions[len(additions)-1].Link(), newDiff(additions, removals), nil
+}
 
-		child, err = shard.MarshalBlock(cshard)
-		if err != nil {
-			return nil, shard.Diff{}, err
-		}
-		additions = append(additions, child)
+// DelIf deletes the value for the given key from the bucket, only if the
+// current value for the key is equal to the expected value. If the current
+// value is not the expected value [ErrConflict] is returned as the error value.
+// An expected value of nil means the key must not exist, in which case no
+// operation occurs.
+func DelIf(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string, expected ipld.Link) (ipld.Link, shard.Diff, error) {
+	err := checkCurrent(ctx, blocks, root, key, expected)
+	if err != nil {
+		return nil, shard.Diff{}, err
 	}
-
-	return additions[len(additions)-1].Link(), shard.Diff{Additions: additions, Removals: removals}, nil
+	if expected == nil {
+		return root, shard.Diff{}, nil
+	}
+	return Del(ctx, blocks, root, key)
 }
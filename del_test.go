@@ -2,8 +2,11 @@ package pail
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
 	"github.com/storacha/go-pail/internal/testutil"
 	"github.com/storacha/go-pail/shard"
 	"github.com/stretchr/testify/require"
@@ -391,4 +394,143 @@ func TestDel(t *testing.T) {
 			},
 		}, materialize(t, bs, r3))
 	})
+
+	t.Run("del last entry in shard removes empty shards up to root", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := rb0.Link()
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+		v2 := testutil.RandomLink(t)
+		r1 := putAll(t, bs, r0, []object{
+			{"a", v0},
+			{"baaa", v1},
+			{"babb", v2},
+		})
+
+		r2, diff1, err := Del(ctx, bs, r1, "baaa")
+		require.NoError(t, err)
+
+		testutil.ApplyDiff(t, diff1, bs)
+
+		r3, diff2, err := Del(ctx, bs, r2, "babb")
+		require.NoError(t, err)
+
+		testutil.ApplyDiff(t, diff2, bs)
+
+		require.Equal(t, []entry{
+			{
+				"a",
+				value{v0, nil},
+			},
+		}, materialize(t, bs, r3))
+
+		o0, err := Get(ctx, bs, r3, "a")
+		require.NoError(t, err)
+		require.Equal(t, v0, o0)
+	})
+}
+
+func TestDelIf(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("del if value is expected value", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		k0 := "test"
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+		r0 := putAll(t, bs, rb0.Link(), []object{{k0, v0}})
+
+		_, _, err = DelIf(ctx, bs, r0, k0, v1)
+		require.ErrorIs(t, err, ErrConflict)
+
+		_, _, err = DelIf(ctx, bs, r0, k0, nil)
+		require.ErrorIs(t, err, ErrConflict)
+
+		r1, diff0, err := DelIf(ctx, bs, r0, k0, v0)
+		require.NoError(t, err)
+
+		testutil.ApplyDiff(t, diff0, bs)
+
+		_, err = Get(ctx, bs, r1, k0)
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("del if key does not exist", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := rb0.Link()
+		r1, diff0, err := DelIf(ctx, bs, r0, "test", nil)
+		require.NoError(t, err)
+		require.Equal(t, r0, r1)
+		require.Len(t, diff0.Additions, 0)
+		require.Len(t, diff0.Removals, 0)
+
+		_, _, err = DelIf(ctx, bs, r0, "test", testutil.RandomLink(t))
+		require.ErrorIs(t, err, ErrConflict)
+	})
+}
+
+func TestPutDelCached(t *testing.T) {
+	ctx := context.Background()
+
+	rb0, err := New()
+	require.NoError(t, err)
+	bs := block.NewMapBlockstore()
+	err = bs.Put(ctx, rb0)
+	require.NoError(t, err)
+	// operations must not modify the decoded shards that are cached
+	cf := block.NewCachingFetcher(bs, 1000)
+
+	roots := []ipld.Link{rb0.Link()}
+	for i := range 100 {
+		r, diff, err := Put(ctx, cf, roots[len(roots)-1], fmt.Sprintf("dir%d/file%03d.txt", i%5, i), testutil.RandomLink(t))
+		require.NoError(t, err)
+		// keep removed shards, so that every root can be read, and store the
+		// bytes only, so that reads from the store decode them again
+		for _, b := range diff.Additions {
+			err = bs.Put(ctx, block.New(b.Link(), b.Bytes()))
+			require.NoError(t, err)
+		}
+		roots = append(roots, r)
+	}
+	for i := range 50 {
+		r, diff, err := Del(ctx, cf, roots[len(roots)-1], fmt.Sprintf("dir%d/file%03d.txt", i%5, i))
+		require.NoError(t, err)
+		for _, b := range diff.Additions {
+			err = bs.Put(ctx, block.New(b.Link(), b.Bytes()))
+			require.NoError(t, err)
+		}
+		roots = append(roots, r)
+	}
+
+	for _, r := range roots {
+		var expected, actual []Entry
+		for e, err := range Entries(ctx, bs, r) {
+			require.NoError(t, err)
+			expected = append(expected, e)
+		}
+		for e, err := range Entries(ctx, cf, r) {
+			require.NoError(t, err)
+			actual = append(actual, e)
+		}
+		require.Equal(t, expected, actual)
+	}
+	require.NotZero(t, cf.Stats().Hits)
 }
@@ -0,0 +1,207 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strings"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/shard"
+)
+
+// Change is a change to the value of a single key between two pail roots.
+type Change struct {
+	Key string
+	// Old is the value of the key in the first root. It is nil if the key was
+	// added.
+	Old ipld.Link
+	// New is the value of the key in the second root. It is nil if the key was
+	// removed.
+	New ipld.Link
+}
+
+// Diff yields the key level changes required to get from pail root a to pail
+// root b, in ascending key order. Subtrees that are linked to by the same shard
+// link in both pails are not fetched, nor are parts of shards that were split
+// by size that are the same in both pails where they are reached.
+func Diff(ctx context.Context, blocks block.Fetcher, a, b ipld.Link) iter.Seq2[Change, error] {
+	return func(yield func(Change, error) bool) {
+		if a.String() == b.String() {
+			return
+		}
+
+		shards := shard.NewFetcher(blocks)
+		acur, err := newDiffCursor(ctx, shards, a)
+		if err != nil {
+			yield(Change{}, fmt.Errorf("getting root: %w", err))
+			return
+		}
+		bcur, err := newDiffCursor(ctx, shards, b)
+		if err != nil {
+			yield(Change{}, fmt.Errorf("getting root: %w", err))
+			return
+		}
+
+		for {
+			ai, aok := acur.peek()
+			bi, bok := bcur.peek()
+
+			var err error
+			switch {
+			case aok && bok && ai.part && bi.part && ai.shard.String() == bi.shard.String():
+				// same part in both, no changes
+				acur.pop()
+				bcur.pop()
+			case aok && ai.part:
+				// parts are expanded when they are reached, since they do not contain
+				// all the keys with their prefix
+				err = acur.expand(ctx)
+			case bok && bi.part:
+				err = bcur.expand(ctx)
+			case !aok && !bok:
+				return
+			case !bok || (aok && ai.shard == nil && bi.shard == nil && ai.key < bi.key):
+				if ai.shard != nil {
+					err = acur.expand(ctx)
+					break
+				}
+				acur.pop()
+				if !yield(Change{Key: ai.key, Old: ai.value}, nil) {
+					return
+				}
+			case !aok || (ai.shard == nil && bi.shard == nil && bi.key < ai.key):
+				if bi.shard != nil {
+					err = bcur.expand(ctx)
+					break
+				}
+				bcur.pop()
+				if !yield(Change{Key: bi.key, New: bi.value}, nil) {
+					return
+				}
+			case ai.shard == nil && bi.shard == nil:
+				acur.pop()
+				bcur.pop()
+				if ai.value.String() != bi.value.String() {
+					if !yield(Change{Key: ai.key, Old: ai.value, New: bi.value}, nil) {
+						return
+					}
+				}
+			case ai.shard != nil && bi.shard != nil:
+				if ai.key == bi.key {
+					// same subtree in both, no changes
+					if ai.shard.String() == bi.shard.String() {
+						acur.pop()
+						bcur.pop()
+						break
+					}
+					err = acur.expand(ctx)
+					if err == nil {
+						err = bcur.expand(ctx)
+					}
+				} else if strings.HasPrefix(bi.key, ai.key) || (!strings.HasPrefix(ai.key, bi.key) && ai.key < bi.key) {
+					// a contains b, or all keys in a sort before b
+					err = acur.expand(ctx)
+				} else {
+					err = bcur.expand(ctx)
+				}
+			case ai.shard != nil:
+				// a key equal to the shard prefix sorts before keys in the shard
+				if bi.key > ai.key {
+					err = acur.expand(ctx)
+					break
+				}
+				bcur.pop()
+				if !yield(Change{Key: bi.key, New: bi.value}, nil) {
+					return
+				}
+			default:
+				if ai.key > bi.key {
+					err = bcur.expand(ctx)
+					break
+				}
+				acur.pop()
+				if !yield(Change{Key: ai.key, Old: ai.value}, nil) {
+					return
+				}
+			}
+			if err != nil {
+				yield(Change{}, fmt.Errorf("getting shard: %w", err))
+				return
+			}
+		}
+	}
+}
+
+// diffItem is either a key and value, or a link to a shard containing keys
+// with the given prefix, or to a part of a shard with the given prefix (see
+// [shard.WithFanout]).
+type diffItem struct {
+	key   string
+	value ipld.Link
+	shard ipld.Link
+	part  bool
+}
+
+// diffCursor iterates over a pail in key order, expanding links to shards
+// only when requested.
+type diffCursor struct {
+	shards *shard.Fetcher
+	// items is a stack of items still to be visited, the next item is last.
+	items []diffItem
+}
+
+func newDiffCursor(ctx context.Context, shards *shard.Fetcher, root ipld.Link) (*diffCursor, error) {
+	rshard, err := shards.GetRoot(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	c := diffCursor{shards: shards}
+	c.push(shard.AsBlock(rshard))
+	return &c, nil
+}
+
+func (c *diffCursor) peek() (diffItem, bool) {
+	if len(c.items) == 0 {
+		return diffItem{}, false
+	}
+	return c.items[len(c.items)-1], true
+}
+
+func (c *diffCursor) pop() {
+	c.items = c.items[:len(c.items)-1]
+}
+
+// expand replaces the shard link at the top of the stack with the entries of
+// the shard.
+func (c *diffCursor) expand(ctx context.Context) error {
+	item := c.items[len(c.items)-1]
+	c.pop()
+	s, err := c.shards.Get(ctx, item.shard)
+	if err != nil {
+		return err
+	}
+	c.push(s)
+	return nil
+}
+
+func (c *diffCursor) push(s shard.BlockView) {
+	entries := s.Value().Entries()
+	if shard.IsFanout(s.Value()) {
+		for i := len(entries) - 1; i >= 0; i-- {
+			c.items = append(c.items, diffItem{key: s.Value().Prefix(), shard: entries[i].Value().Shard(), part: true})
+		}
+		return
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		key := s.Value().Prefix() + entries[i].Key()
+		v := entries[i].Value()
+		if v.Shard() != nil {
+			c.items = append(c.items, diffItem{key: key, shard: v.Shard()})
+		}
+		if v.Value() != nil {
+			c.items = append(c.items, diffItem{key: key, value: v.Value()})
+		}
+	}
+}
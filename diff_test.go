@@ -0,0 +1,209 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("same root", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := putAll(t, bs, rb0.Link(), []object{{"aaaa", testutil.RandomLink(t)}})
+
+		bs.GetCount = 0
+		changes := collectChanges(t, Diff(ctx, bs, r0, r0))
+		require.Len(t, changes, 0)
+		require.Equal(t, 0, bs.GetCount)
+	})
+
+	t.Run("added, removed and updated keys", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+		v2 := testutil.RandomLink(t)
+		v3 := testutil.RandomLink(t)
+		v4 := testutil.RandomLink(t)
+		r0 := putAll(t, bs, rb0.Link(), []object{
+			{"aaaa", v0},
+			{"aabb", v1},
+			{"b", v2},
+		})
+
+		r1 := putRetain(t, bs, r0, []object{
+			{"aa", v3},
+			{"aabb", v4},
+			{"c", v3},
+		})
+		r1 = delRetain(t, bs, r1, "b")
+
+		changes := collectChanges(t, Diff(ctx, bs, r0, r1))
+		require.Equal(t, []Change{
+			{Key: "aa", New: v3},
+			{Key: "aabb", Old: v1, New: v4},
+			{Key: "b", Old: v2},
+			{Key: "c", New: v3},
+		}, changes)
+
+		changes = collectChanges(t, Diff(ctx, bs, r1, r0))
+		require.Equal(t, []Change{
+			{Key: "aa", Old: v3},
+			{Key: "aabb", Old: v4, New: v1},
+			{Key: "b", New: v2},
+			{Key: "c", Old: v3},
+		}, changes)
+	})
+
+	t.Run("value entry and sharded entry for same key", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+		r0 := putAll(t, bs, rb0.Link(), []object{{"abc", v0}})
+
+		// "abc" is moved into a shard, but it's value remains the same
+		r1 := putRetain(t, bs, r0, []object{{"abd", v1}})
+
+		changes := collectChanges(t, Diff(ctx, bs, r0, r1))
+		require.Equal(t, []Change{{Key: "abd", New: v1}}, changes)
+	})
+
+	t.Run("matches entries", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		var objects []object
+		for i := range 50 {
+			objects = append(objects, object{fmt.Sprintf("%d", i*7), testutil.RandomLink(t)})
+		}
+		r0 := putAll(t, bs, rb0.Link(), objects)
+
+		r1 := r0
+		for i := range 50 {
+			if i%4 == 0 {
+				r1 = delRetain(t, bs, r1, fmt.Sprintf("%d", i*7))
+			} else if i%4 == 1 {
+				r1 = putRetain(t, bs, r1, []object{{fmt.Sprintf("%d", i*3), testutil.RandomLink(t)}})
+			}
+		}
+
+		expect := map[string]Change{}
+		for e, err := range Entries(ctx, bs, r0) {
+			require.NoError(t, err)
+			expect[e.Key] = Change{Key: e.Key, Old: e.Value}
+		}
+		for e, err := range Entries(ctx, bs, r1) {
+			require.NoError(t, err)
+			c := expect[e.Key]
+			if c.Old != nil && c.Old.String() == e.Value.String() {
+				delete(expect, e.Key)
+				continue
+			}
+			expect[e.Key] = Change{Key: e.Key, Old: c.Old, New: e.Value}
+		}
+
+		changes := collectChanges(t, Diff(ctx, bs, r0, r1))
+		require.Len(t, changes, len(expect))
+		for i, c := range changes {
+			require.Equal(t, expect[c.Key], c)
+			if i > 0 {
+				require.Less(t, changes[i-1].Key, c.Key)
+			}
+		}
+	})
+
+	t.Run("does not fetch unchanged shards", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		var objects []object
+		for _, pfx := range []string{"a", "b", "c", "d"} {
+			for i := range 10 {
+				objects = append(objects, object{fmt.Sprintf("%s/%d", pfx, i), testutil.RandomLink(t)})
+			}
+		}
+		r0 := putAll(t, bs, rb0.Link(), objects)
+
+		v := testutil.RandomLink(t)
+		r1 := putRetain(t, bs, r0, []object{{"c/5", v}})
+
+		bs.GetCount = 0
+		changes := collectChanges(t, Diff(ctx, bs, r0, r1))
+		require.Len(t, changes, 1)
+		require.Equal(t, "c/5", changes[0].Key)
+		require.Equal(t, v, changes[0].New)
+
+		// only the roots and the changed "c" and "c/" shards are fetched
+		require.Equal(t, 6, bs.GetCount)
+	})
+}
+
+func collectChanges(t *testing.T, changes func(func(Change, error) bool)) []Change {
+	var results []Change
+	for c, err := range changes {
+		require.NoError(t, err)
+		results = append(results, c)
+	}
+	return results
+}
+
+// putRetain puts the objects to the pail, without removing any blocks from the
+// blockstore, so that previous roots can still be read.
+func putRetain(t *testing.T, bs testutil.Blockstore, root ipld.Link, objects []object) ipld.Link {
+	ctx := context.Background()
+	for _, o := range objects {
+		r, diff, err := Put(ctx, bs, root, o.key, o.value)
+		require.NoError(t, err)
+		for _, b := range diff.Additions {
+			err = bs.Put(ctx, b)
+			require.NoError(t, err)
+		}
+		root = r
+	}
+	return root
+}
+
+// delRetain deletes the key from the pail, without removing any blocks from
+// the blockstore, so that previous roots can still be read.
+func delRetain(t *testing.T, bs testutil.Blockstore, root ipld.Link, key string) ipld.Link {
+	ctx := context.Background()
+	r, diff, err := Del(ctx, bs, root, key)
+	require.NoError(t, err)
+	for _, b := range diff.Additions {
+		err = bs.Put(ctx, b)
+		require.NoError(t, err)
+	}
+	return r
+}
@@ -14,11 +14,14 @@ import (
 type EntriesOption func(*entriesOptions)
 
 type entriesOptions struct {
-	prefix string
-	gt     string
-	gte    string
-	lt     string
-	lte    string
+	prefix  string
+	gt      string
+	gte     string
+	lt      string
+	lte     string
+	reverse bool
+	limit   int
+	cursor  string
 }
 
 func WithKeyPrefix(prefix string) EntriesOption {
@@ -51,11 +54,42 @@ func WithKeyLessThanOrEqual(lte string) EntriesOption {
 	}
 }
 
+// WithReverse causes entries to be listed in descending key order.
+func WithReverse() EntriesOption {
+	return func(o *entriesOptions) {
+		o.reverse = true
+	}
+}
+
+// WithLimit limits the number of entries that are listed.
+func WithLimit(n int) EntriesOption {
+	return func(o *entriesOptions) {
+		o.limit = n
+	}
+}
+
+// WithCursor continues listing entries after the key encoded in a cursor
+// created by [Cursor]. Iteration fails with [ErrCursorRootMismatch] if the
+// cursor was created for a different pail root, or with
+// [ErrCursorDirectionMismatch] if it was created for iteration in the other
+// direction.
+func WithCursor(cursor string) EntriesOption {
+	return func(o *entriesOptions) {
+		o.cursor = cursor
+	}
+}
+
 type Entry struct {
+	// Key is the key of the entry. In a pail with the [shard.KeyCharsBinary]
+	// key character set it is a string of raw bytes, see [Entry.KeyBytes].
 	Key   string
 	Value ipld.Link
 }
 
+// Entries lists the entries of the pail in key order, optionally filtered by a
+// key prefix or range. Keys are compared byte by byte, so in a pail with the
+// [shard.KeyCharsBinary] key character set prefixes and range bounds are passed
+// as strings of raw bytes, e.g. WithKeyGreaterThan(string(b)).
 func Entries(ctx context.Context, blocks block.Fetcher, root ipld.Link, opts ...EntriesOption) iter.Seq2[Entry, error] {
 	o := &entriesOptions{}
 	for _, opt := range opts {
@@ -72,6 +106,30 @@ func Entries(ctx context.Context, blocks block.Fetcher, root ipld.Link, opts ...
 	hasKeyUpperBoundRangeExclusive := hasKeyUpperBoundRange && isKeyUpperBoundRangeExclusive(o)
 	hasKeyUpperAndLowerBoundRange := hasKeyLowerBoundRange && hasKeyUpperBoundRange
 
+	// entries are listed after the cursor key, which is an additional bound
+	// that applies on top of the prefix and range options.
+	var after string
+	hasCursor := o.cursor != ""
+	if hasCursor {
+		croot, key, reverse, err := parseCursor(o.cursor)
+		if err != nil {
+			return func(yield func(Entry, error) bool) {
+				yield(Entry{}, err)
+			}
+		}
+		if croot.String() != root.String() {
+			return func(yield func(Entry, error) bool) {
+				yield(Entry{}, fmt.Errorf("%w: %s", ErrCursorRootMismatch, croot))
+			}
+		}
+		if reverse != o.reverse {
+			return func(yield func(Entry, error) bool) {
+				yield(Entry{}, ErrCursorDirectionMismatch)
+			}
+		}
+		after = key
+	}
+
 	shards := shard.NewFetcher(blocks)
 	rshard, err := shards.GetRoot(ctx, root)
 	if err != nil {
@@ -80,68 +138,155 @@ func Entries(ctx context.Context, blocks block.Fetcher, root ipld.Link, opts ...
 		}
 	}
 
+	// match determines if a key should be yielded given the prefix or range
+	// options.
+	match := func(key string) bool {
+		if hasCursor && ((!o.reverse && key <= after) || (o.reverse && key >= after)) {
+			return false
+		}
+		return (hasKeyPrefix && strings.HasPrefix(key, o.prefix)) ||
+			(hasKeyRange && hasKeyUpperAndLowerBoundRange && (((hasKeyLowerBoundRangeExclusive && key > o.gt) || (hasKeyLowerBoundRangeInclusive && key >= o.gte)) &&
+				((hasKeyUpperBoundRangeExclusive && key < o.lt) || (hasKeyUpperBoundRangeInclusive && key <= o.lte)))) ||
+			(hasKeyRange && !hasKeyUpperAndLowerBoundRange && ((hasKeyLowerBoundRangeExclusive && key > o.gt) || (hasKeyLowerBoundRangeInclusive && key >= o.gte) ||
+				(hasKeyUpperBoundRangeExclusive && key < o.lt) || (hasKeyUpperBoundRangeInclusive && key <= o.lte))) ||
+			(!hasKeyPrefix && !hasKeyRange)
+	}
+
+	// prune determines if the shard linked to from an entry with the passed key
+	// can be skipped, because none of the keys it contains are a match.
+	prune := func(key string) bool {
+		if hasCursor {
+			k := trunc(key, min(len(key), len(after)))
+			a := trunc(after, min(len(key), len(after)))
+			if (!o.reverse && k < a) || (o.reverse && k > a) {
+				return true
+			}
+		}
+		if hasKeyPrefix {
+			if len(o.prefix) <= len(key) && !strings.HasPrefix(key, o.prefix) {
+				return true
+			}
+			if len(o.prefix) > len(key) && !strings.HasPrefix(o.prefix, key) {
+				return true
+			}
+			return false
+		}
+		return (hasKeyLowerBoundRangeExclusive && (trunc(key, min(len(key), len(o.gt))) < trunc(o.gt, min(len(key), len(o.gt))))) ||
+			(hasKeyLowerBoundRangeInclusive && (trunc(key, min(len(key), len(o.gte))) < trunc(o.gte, min(len(key), len(o.gte))))) ||
+			(hasKeyUpperBoundRangeExclusive && (trunc(key, min(len(key), len(o.lt))) > trunc(o.lt, min(len(key), len(o.lt))))) ||
+			(hasKeyUpperBoundRangeInclusive && (trunc(key, min(len(key), len(o.lte))) > trunc(o.lte, min(len(key), len(o.lte)))))
+	}
+
+	// prunePart determines if a part of a fanout shard can be skipped, given
+	// the range of the keys it contains. Keys are at least lower, and less than
+	// upper unless the part is the last.
+	prunePart := func(lower, upper string, last bool) bool {
+		if hasCursor {
+			if (!o.reverse && !last && upper <= after) || (o.reverse && lower >= after) {
+				return true
+			}
+		}
+		if hasKeyPrefix {
+			return (!last && upper <= o.prefix) || (lower > o.prefix && !strings.HasPrefix(lower, o.prefix))
+		}
+		return (hasKeyLowerBoundRangeExclusive && !last && upper <= o.gt) ||
+			(hasKeyLowerBoundRangeInclusive && !last && upper <= o.gte) ||
+			(hasKeyUpperBoundRangeExclusive && lower >= o.lt) ||
+			(hasKeyUpperBoundRangeInclusive && lower > o.lte)
+	}
+
 	var ents func(s block.BlockView[shard.Shard]) iter.Seq2[Entry, error]
 	ents = func(s block.BlockView[shard.Shard]) iter.Seq2[Entry, error] {
 		return func(yield func(Entry, error) bool) {
-			for _, entry := range s.Value().Entries() {
-				key := s.Value().Prefix() + entry.Key()
+			// yieldShard yields the entries of the shard linked to from the entry,
+			// returning false if iteration should stop.
+			yieldShard := func(key string, entry shard.Entry) bool {
+				if prune(key) {
+					return true
+				}
 
-				if entry.Value().Shard() != nil {
-					if entry.Value().Value() != nil {
-						if (hasKeyPrefix && strings.HasPrefix(key, o.prefix)) ||
-							(hasKeyUpperAndLowerBoundRange && (((hasKeyLowerBoundRangeExclusive && key > o.gt) || (hasKeyLowerBoundRangeInclusive && key >= o.gte)) && ((hasKeyUpperBoundRangeExclusive && key < o.lt) || (hasKeyUpperBoundRangeInclusive && key <= o.lte)))) ||
-							(hasKeyLowerBoundRangeExclusive && key > o.gt) ||
-							(hasKeyLowerBoundRangeInclusive && key >= o.gte) ||
-							(hasKeyUpperBoundRangeExclusive && key < o.lt) ||
-							(hasKeyUpperBoundRangeInclusive && key <= o.lte) ||
-							(!hasKeyPrefix && !hasKeyRange) {
-							if !yield(Entry{key, entry.Value().Value()}, nil) {
-								return
-							}
-						}
+				c, err := shards.Get(ctx, entry.Value().Shard())
+				if err != nil {
+					yield(Entry{}, fmt.Errorf("getting shard: %w", err))
+					return false
+				}
+
+				for entry, err := range ents(c) {
+					if !yield(entry, err) || err != nil {
+						return false
 					}
+				}
+				return true
+			}
 
-					if hasKeyPrefix {
-						if len(o.prefix) <= len(key) && !strings.HasPrefix(key, o.prefix) {
-							continue
-						}
-						if len(o.prefix) > len(key) && !strings.HasPrefix(o.prefix, key) {
-							continue
-						}
-					} else if (hasKeyLowerBoundRangeExclusive && (trunc(key, min(len(key), len(o.gt))) < trunc(o.gt, min(len(key), len(o.gt))))) ||
-						(hasKeyLowerBoundRangeInclusive && (trunc(key, min(len(key), len(o.gte))) < trunc(o.gte, min(len(key), len(o.gte))))) ||
-						(hasKeyUpperBoundRangeExclusive && (trunc(key, min(len(key), len(o.lt))) > trunc(o.lt, min(len(key), len(o.lt))))) ||
-						(hasKeyUpperBoundRangeInclusive && (trunc(key, min(len(key), len(o.lte))) > trunc(o.lte, min(len(key), len(o.lte))))) {
+			entries := s.Value().Entries()
+			if shard.IsFanout(s.Value()) {
+				for i := range entries {
+					if o.reverse {
+						i = len(entries) - 1 - i
+					}
+					lower, upper, last := partBounds(entries, i)
+					if prunePart(s.Value().Prefix()+lower, s.Value().Prefix()+upper, last) {
 						continue
 					}
 
-					c, err := shards.Get(ctx, entry.Value().Shard())
+					c, err := shards.Get(ctx, entries[i].Value().Shard())
 					if err != nil {
 						yield(Entry{}, fmt.Errorf("getting shard: %w", err))
 						return
 					}
-
 					for entry, err := range ents(c) {
 						if !yield(entry, err) || err != nil {
 							return
 						}
 					}
-				} else {
-					if (hasKeyPrefix && strings.HasPrefix(key, o.prefix)) ||
-						(hasKeyRange && hasKeyUpperAndLowerBoundRange && (((hasKeyLowerBoundRangeExclusive && key > o.gt) || (hasKeyLowerBoundRangeInclusive && key >= o.gte)) &&
-							((hasKeyUpperBoundRangeExclusive && key < o.lt) || (hasKeyUpperBoundRangeInclusive && key <= o.lte)))) ||
-						(hasKeyRange && !hasKeyUpperAndLowerBoundRange && ((hasKeyLowerBoundRangeExclusive && key > o.gt) || (hasKeyLowerBoundRangeInclusive && key >= o.gte) ||
-							(hasKeyUpperBoundRangeExclusive && key < o.lt) || (hasKeyUpperBoundRangeInclusive && key <= o.lte))) ||
-						(!hasKeyPrefix && !hasKeyRange) {
-						if !yield(Entry{key, entry.Value().Value()}, nil) {
-							return
-						}
+				}
+				return
+			}
+
+			for i := range entries {
+				entry := entries[i]
+				if o.reverse {
+					entry = entries[len(entries)-1-i]
+				}
+				key := s.Value().Prefix() + entry.Key()
+
+				// keys in a linked shard are greater than the key of the entry
+				if o.reverse && entry.Value().Shard() != nil {
+					if !yieldShard(key, entry) {
+						return
 					}
 				}
+
+				if entry.Value().Value() != nil && match(key) {
+					if !yield(Entry{key, entry.Value().Value()}, nil) {
+						return
+					}
+				}
+
+				if !o.reverse && entry.Value().Shard() != nil {
+					if !yieldShard(key, entry) {
+						return
+					}
+				}
+			}
+		}
+	}
+	if o.limit <= 0 {
+		return ents(shard.AsBlock(rshard))
+	}
+	return func(yield func(Entry, error) bool) {
+		var n int
+		for entry, err := range ents(shard.AsBlock(rshard)) {
+			if !yield(entry, err) || err != nil {
+				return
+			}
+			n++
+			if n >= o.limit {
+				return
 			}
 		}
 	}
-	return ents(shard.AsBlock(rshard))
 }
 
 func isKeyPrefix(o *entriesOptions) bool {
@@ -2,6 +2,7 @@ package pail
 
 import (
 	"context"
+	"fmt"
 	"slices"
 	"strings"
 	"testing"
@@ -280,6 +281,342 @@ func TestEntries(t *testing.T) {
 			require.Equal(t, o.value.String(), results[i].Value.String())
 		}
 	})
+
+	t.Run("lists entries by key greater than and less than with sharded values", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := rb0.Link()
+		objects := []object{
+			{"a", testutil.RandomLink(t)},
+			{"aa", testutil.RandomLink(t)},
+			{"c", testutil.RandomLink(t)},
+			{"cc", testutil.RandomLink(t)},
+			{"e", testutil.RandomLink(t)},
+			{"ee", testutil.RandomLink(t)},
+		}
+		r1 := putAll(t, bs, r0, objects)
+
+		// "a" and "e" are entries with a value and a shard link, which must match
+		// both bounds, not only one of them
+		var results []Entry
+		for e, err := range Entries(ctx, bs, r1, WithKeyGreaterThan("b"), WithKeyLessThan("d")) {
+			require.NoError(t, err)
+			results = append(results, e)
+		}
+		require.Len(t, results, 2)
+		require.Equal(t, "c", results[0].Key)
+		require.Equal(t, "cc", results[1].Key)
+
+		results = nil
+		for e, err := range Entries(ctx, bs, r1, WithKeyGreaterThanOrEqual("aa"), WithKeyLessThanOrEqual("c")) {
+			require.NoError(t, err)
+			results = append(results, e)
+		}
+		require.Len(t, results, 2)
+		require.Equal(t, "aa", results[0].Key)
+		require.Equal(t, "c", results[1].Key)
+	})
+
+	t.Run("lists entries in reverse lexicographical order", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := rb0.Link()
+		objects := []object{
+			{"c", testutil.RandomLink(t)},
+			{"d", testutil.RandomLink(t)},
+			{"a", testutil.RandomLink(t)},
+			{"aa", testutil.RandomLink(t)},
+			{"aaaa", testutil.RandomLink(t)},
+			{"aabb", testutil.RandomLink(t)},
+			{"b", testutil.RandomLink(t)},
+		}
+		r1 := putAll(t, bs, r0, objects)
+
+		var results []Entry
+		for e, err := range Entries(ctx, bs, r1, WithReverse()) {
+			require.NoError(t, err)
+			results = append(results, e)
+		}
+		require.Len(t, results, len(objects))
+
+		slices.SortFunc(objects, objectKeySort)
+		slices.Reverse(objects)
+
+		for i, o := range objects {
+			require.Equal(t, o.key, results[i].Key)
+			require.Equal(t, o.value.String(), results[i].Value.String())
+		}
+	})
+
+	t.Run("lists entries in reverse by prefix", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := rb0.Link()
+		objects := []object{
+			{"ceee", testutil.RandomLink(t)},
+			{"deee", testutil.RandomLink(t)},
+			{"dooo", testutil.RandomLink(t)},
+			{"d", testutil.RandomLink(t)},
+			{"beee", testutil.RandomLink(t)},
+		}
+		r1 := putAll(t, bs, r0, objects)
+
+		pfx := "d"
+		var expectObjs []object
+		for _, o := range objects {
+			if strings.HasPrefix(o.key, pfx) {
+				expectObjs = append(expectObjs, o)
+			}
+		}
+		slices.SortFunc(expectObjs, objectKeySort)
+		slices.Reverse(expectObjs)
+
+		var results []Entry
+		for e, err := range Entries(ctx, bs, r1, WithKeyPrefix(pfx), WithReverse()) {
+			require.NoError(t, err)
+			results = append(results, e)
+		}
+		require.Len(t, results, len(expectObjs))
+
+		for i, o := range expectObjs {
+			require.Equal(t, o.key, results[i].Key)
+			require.Equal(t, o.value.String(), results[i].Value.String())
+		}
+	})
+
+	t.Run("lists entries in reverse by key range", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := rb0.Link()
+		var objects []object
+		for i := range 30 {
+			objects = append(objects, object{fmt.Sprintf("2024-01-%02d", i+1), testutil.RandomLink(t)})
+		}
+		r1 := putAll(t, bs, r0, objects)
+
+		gte := "2024-01-10"
+		lt := "2024-01-20"
+		var expectObjs []object
+		for _, o := range objects {
+			if o.key >= gte && o.key < lt {
+				expectObjs = append(expectObjs, o)
+			}
+		}
+		slices.SortFunc(expectObjs, objectKeySort)
+		slices.Reverse(expectObjs)
+
+		var results []Entry
+		for e, err := range Entries(ctx, bs, r1, WithKeyGreaterThanOrEqual(gte), WithKeyLessThan(lt), WithReverse()) {
+			require.NoError(t, err)
+			results = append(results, e)
+		}
+		require.Len(t, results, len(expectObjs))
+
+		for i, o := range expectObjs {
+			require.Equal(t, o.key, results[i].Key)
+			require.Equal(t, o.value.String(), results[i].Value.String())
+		}
+
+		// latest N items without reading everything
+		total := len(reachable(t, bs, r1))
+		bs.GetCount = 0
+
+		results = nil
+		for e, err := range Entries(ctx, bs, r1, WithReverse()) {
+			require.NoError(t, err)
+			results = append(results, e)
+			if len(results) == 3 {
+				break
+			}
+		}
+		require.Equal(t, "2024-01-30", results[0].Key)
+		require.Equal(t, "2024-01-29", results[1].Key)
+		require.Equal(t, "2024-01-28", results[2].Key)
+		require.Less(t, bs.GetCount, total)
+	})
+
+	t.Run("lists entries with limit", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := rb0.Link()
+		objects := []object{
+			{"c", testutil.RandomLink(t)},
+			{"d", testutil.RandomLink(t)},
+			{"a", testutil.RandomLink(t)},
+			{"b", testutil.RandomLink(t)},
+		}
+		r1 := putAll(t, bs, r0, objects)
+
+		var results []Entry
+		for e, err := range Entries(ctx, bs, r1, WithLimit(3)) {
+			require.NoError(t, err)
+			results = append(results, e)
+		}
+		require.Len(t, results, 3)
+
+		slices.SortFunc(objects, objectKeySort)
+
+		for i, o := range objects[:3] {
+			require.Equal(t, o.key, results[i].Key)
+			require.Equal(t, o.value.String(), results[i].Value.String())
+		}
+	})
+
+	t.Run("paginates entries with cursor", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := rb0.Link()
+		var objects []object
+		for i := range 25 {
+			objects = append(objects, object{fmt.Sprintf("file/%d", i), testutil.RandomLink(t)})
+		}
+		objects = append(objects, object{"other", testutil.RandomLink(t)})
+		r1 := putAll(t, bs, r0, objects)
+
+		var expectObjs []object
+		for _, o := range objects {
+			if strings.HasPrefix(o.key, "file/") {
+				expectObjs = append(expectObjs, o)
+			}
+		}
+
+		for _, reverse := range []bool{false, true} {
+			slices.SortFunc(expectObjs, objectKeySort)
+			opts := []EntriesOption{WithKeyPrefix("file/"), WithLimit(10)}
+			if reverse {
+				slices.Reverse(expectObjs)
+				opts = append(opts, WithReverse())
+			}
+
+			var results []Entry
+			var pages int
+			var cursor string
+			for {
+				var page []Entry
+				popts := opts
+				if cursor != "" {
+					popts = append(popts, WithCursor(cursor))
+				}
+				for e, err := range Entries(ctx, bs, r1, popts...) {
+					require.NoError(t, err)
+					page = append(page, e)
+				}
+				if len(page) == 0 {
+					break
+				}
+				pages++
+				results = append(results, page...)
+				cursor, err = Cursor(r1, page[len(page)-1].Key, opts...)
+				require.NoError(t, err)
+			}
+			require.Equal(t, 3, pages)
+			require.Len(t, results, len(expectObjs))
+
+			for i, o := range expectObjs {
+				require.Equal(t, o.key, results[i].Key)
+				require.Equal(t, o.value.String(), results[i].Value.String())
+			}
+		}
+	})
+
+	t.Run("cursor for different root", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := rb0.Link()
+		r1 := putAll(t, bs, r0, []object{{"a", testutil.RandomLink(t)}})
+
+		cursor, err := Cursor(r0, "a")
+		require.NoError(t, err)
+
+		var errs []error
+		for _, err := range Entries(ctx, bs, r1, WithCursor(cursor)) {
+			errs = append(errs, err)
+		}
+		require.Len(t, errs, 1)
+		require.ErrorIs(t, errs[0], ErrCursorRootMismatch)
+	})
+
+	t.Run("cursor for other direction", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r1 := putAll(t, bs, rb0.Link(), []object{
+			{"a", testutil.RandomLink(t)},
+			{"b", testutil.RandomLink(t)},
+		})
+
+		forward, err := Cursor(r1, "a")
+		require.NoError(t, err)
+		reverse, err := Cursor(r1, "b", WithReverse())
+		require.NoError(t, err)
+
+		for _, opts := range [][]EntriesOption{
+			{WithCursor(forward), WithReverse()},
+			{WithCursor(reverse)},
+		} {
+			var errs []error
+			for _, err := range Entries(ctx, bs, r1, opts...) {
+				errs = append(errs, err)
+			}
+			require.Len(t, errs, 1)
+			require.ErrorIs(t, errs[0], ErrCursorDirectionMismatch)
+		}
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		var errs []error
+		for _, err := range Entries(ctx, bs, rb0.Link(), WithCursor("not a cursor")) {
+			errs = append(errs, err)
+		}
+		require.Len(t, errs, 1)
+		require.ErrorIs(t, errs[0], ErrInvalidCursor)
+	})
 }
 
 func objectKeySort(a, b object) int {
@@ -3,6 +3,10 @@ package pail
 import (
 	"context"
 	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
 
 	"github.com/ipld/go-ipld-prime"
 	"github.com/storacha/go-pail/block"
@@ -13,6 +17,12 @@ var ErrNotFound = errors.New("not found")
 
 // Get the stored value for the given key from the bucket. If the key is not
 // found, [ErrNotFound] is returned as the error value.
+//
+// Values stored inline in the shard are returned as an inline link, which can
+// be decoded using [shard.InlineNode].
+//
+// In a pail with the [shard.KeyCharsBinary] key character set the key is a
+// string of raw bytes, see [GetBytes].
 func Get(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string) (ipld.Link, error) {
 	shards := shard.NewFetcher(blocks)
 	rshard, err := shards.GetRoot(ctx, root)
@@ -34,8 +44,125 @@ func Get(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string)
 			break
 		}
 	}
-	if entry == nil {
+	// an entry may be a link to a shard without a value
+	if entry == nil || entry.Value().Value() == nil {
 		return nil, ErrNotFound
 	}
 	return entry.Value().Value(), nil
 }
+
+// Has determines if a value is stored for the given key in the bucket. In a
+// pail with the [shard.KeyCharsBinary] key character set the key is a string of
+// raw bytes, see [HasBytes].
+func Has(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string) (bool, error) {
+	_, err := Get(ctx, blocks, root, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// GetMany gets the stored values for the given keys from the bucket. Keys are
+// sorted and the shards are traversed once, so shards shared by the paths to
+// multiple keys are fetched only once.
+//
+// An entry is yielded for each distinct key, in ascending key order. If a key
+// is not found, an entry with no value is yielded along with [ErrNotFound] and
+// iteration continues. Any other error ends iteration.
+func GetMany(ctx context.Context, blocks block.Fetcher, root ipld.Link, keys []string) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		shards := shard.NewFetcher(blocks)
+		rshard, err := shards.GetRoot(ctx, root)
+		if err != nil {
+			yield(Entry{}, err)
+			return
+		}
+
+		keys = slices.Clone(keys)
+		slices.Sort(keys)
+		keys = slices.Compact(keys)
+
+		getMany(ctx, shards, shard.AsBlock(rshard), keys, yield)
+	}
+}
+
+// getMany yields the values for the sorted keys from the passed shard and the
+// shards it links to. It returns false if iteration should stop.
+func getMany(ctx context.Context, shards *shard.Fetcher, s shard.BlockView, keys []string, yield func(Entry, error) bool) bool {
+	prefix := s.Value().Prefix()
+	if shard.IsFanout(s.Value()) {
+		entries := s.Value().Entries()
+		for len(keys) > 0 {
+			i := route(entries, keys[0][len(prefix):])
+			// all subsequent keys that belong to the same part
+			n := 1
+			for n < len(keys) && route(entries, keys[n][len(prefix):]) == i {
+				n++
+			}
+
+			part := entries[i].Value().Shard()
+			c, err := shards.Get(ctx, part)
+			if err != nil {
+				yield(Entry{}, fmt.Errorf("getting shard %s: %w", part.String(), err))
+				return false
+			}
+			if !getMany(ctx, shards, c, keys[:n], yield) {
+				return false
+			}
+			keys = keys[n:]
+		}
+		return true
+	}
+	for len(keys) > 0 {
+		key := keys[0]
+		skey := key[len(prefix):] // key within the shard
+
+		var entry shard.Entry
+		var child shard.Entry
+		for _, e := range s.Value().Entries() {
+			if e.Key() == skey {
+				entry = e
+				break
+			}
+			if strings.HasPrefix(skey, e.Key()) && e.Value().Shard() != nil {
+				child = e
+				break
+			}
+		}
+
+		if child == nil {
+			keys = keys[1:]
+			if entry == nil || entry.Value().Value() == nil {
+				if !yield(Entry{Key: key}, ErrNotFound) {
+					return false
+				}
+				continue
+			}
+			if !yield(Entry{key, entry.Value().Value()}, nil) {
+				return false
+			}
+			continue
+		}
+
+		// all subsequent keys with the same prefix are in the child shard
+		cprefix := prefix + child.Key()
+		n := 1
+		for n < len(keys) && strings.HasPrefix(keys[n], cprefix) {
+			n++
+		}
+
+		c, err := shards.Get(ctx, child.Value().Shard())
+		if err != nil {
+			yield(Entry{}, fmt.Errorf("getting shard %s: %w", child.Value().Shard().String(), err))
+			return false
+		}
+		if !getMany(ctx, shards, c, keys[:n], yield) {
+			return false
+		}
+		keys = keys[n:]
+	}
+	return true
+}
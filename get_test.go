@@ -3,6 +3,7 @@ package pail
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/storacha/go-pail/internal/testutil"
@@ -145,4 +146,175 @@ func TestGet(t *testing.T) {
 		require.Error(t, err)
 		require.True(t, errors.Is(err, ErrNotFound))
 	})
+
+	t.Run("not found for shard link without value", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+		r0 := putAll(t, bs, rb0.Link(), []object{
+			{"aaaa", v0},
+			{"aabb", v1},
+		})
+
+		// "a" and "aa" are entries that link to shards, without values
+		_, err = Get(ctx, bs, r0, "a")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		_, err = Get(ctx, bs, r0, "aa")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		v2 := testutil.RandomLink(t)
+		r1 := putAll(t, bs, r0, []object{{"aa", v2}})
+
+		o2, err := Get(ctx, bs, r1, "aa")
+		require.NoError(t, err)
+		require.Equal(t, v2, o2)
+
+		o0, err := Get(ctx, bs, r1, "aaaa")
+		require.NoError(t, err)
+		require.Equal(t, v0, o0)
+
+		_, err = Get(ctx, bs, r1, "a")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestHas(t *testing.T) {
+	ctx := context.Background()
+
+	rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+	require.NoError(t, err)
+
+	bs := testutil.NewBlockstore()
+	err = bs.Put(ctx, rb0)
+	require.NoError(t, err)
+
+	r0 := putAll(t, bs, rb0.Link(), []object{
+		{"a", testutil.RandomLink(t)},
+		{"aaaa", testutil.RandomLink(t)},
+		{"aabb", testutil.RandomLink(t)},
+	})
+
+	vectors := []struct {
+		Key string
+		Has bool
+	}{
+		{"a", true},
+		{"aa", false},
+		{"aaa", false},
+		{"aaaa", true},
+		{"aabb", true},
+		{"b", false},
+	}
+	for _, v := range vectors {
+		t.Run(v.Key, func(t *testing.T) {
+			ok, err := Has(ctx, bs, r0, v.Key)
+			require.NoError(t, err)
+			require.Equal(t, v.Has, ok)
+		})
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("gets many keys", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		var objects []object
+		for i := range 100 {
+			objects = append(objects, object{fmt.Sprintf("key/%d", i), testutil.RandomLink(t)})
+		}
+		objects = append(objects, object{"key", testutil.RandomLink(t)})
+		r0 := putAll(t, bs, rb0.Link(), objects)
+
+		keys := []string{"key/99", "key/5", "missing", "key", "key/50", "key/5", "key/"}
+		var results []Entry
+		var errs []error
+		for e, err := range GetMany(ctx, bs, r0, keys) {
+			results = append(results, e)
+			errs = append(errs, err)
+		}
+
+		values := map[string]Entry{}
+		for _, o := range objects {
+			values[o.key] = Entry{o.key, o.value}
+		}
+
+		require.Equal(t, []Entry{
+			values["key"],
+			{Key: "key/"},
+			values["key/5"],
+			values["key/50"],
+			values["key/99"],
+			{Key: "missing"},
+		}, results)
+		require.Equal(t, []error{nil, ErrNotFound, nil, nil, nil, ErrNotFound}, errs)
+	})
+
+	t.Run("fetches shared shards once", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		var objects []object
+		var keys []string
+		for i := range 100 {
+			key := fmt.Sprintf("key/%d", i)
+			objects = append(objects, object{key, testutil.RandomLink(t)})
+			keys = append(keys, key)
+		}
+		r0 := putAll(t, bs, rb0.Link(), objects)
+
+		total := len(reachable(t, bs, r0))
+		bs.GetCount = 0
+
+		var results []Entry
+		for e, err := range GetMany(ctx, bs, r0, keys) {
+			require.NoError(t, err)
+			results = append(results, e)
+		}
+		require.Len(t, results, len(keys))
+		require.Equal(t, total, bs.GetCount)
+	})
+
+	t.Run("missing shard", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := putAll(t, bs, rb0.Link(), []object{
+			{"aaaa", testutil.RandomLink(t)},
+			{"aabb", testutil.RandomLink(t)},
+		})
+
+		rshard, err := shard.NewFetcher(bs).Get(ctx, r0)
+		require.NoError(t, err)
+		err = bs.Del(ctx, rshard.Value().Entries()[0].Value().Shard())
+		require.NoError(t, err)
+
+		var errs []error
+		for _, err := range GetMany(ctx, bs, r0, []string{"aaaa", "aabb"}) {
+			errs = append(errs, err)
+		}
+		require.Len(t, errs, 1)
+		require.ErrorIs(t, errs[0], testutil.ErrNotFound)
+	})
 }
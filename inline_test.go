@@ -0,0 +1,171 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/ipld/multicodec"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInlineValues(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("put and get inline values", func(t *testing.T) {
+		rb0, err := New(shard.WithMaxInlineSize(64))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		v0 := inlineLink(t, basicnode.NewString("small"))
+		v1 := inlineLink(t, basicnode.NewInt(138))
+		v2 := testutil.RandomLink(t)
+		v3 := inlineLink(t, basicnode.NewBytes([]byte{0, 1, 2}))
+		r0 := putAll(t, bs, rb0.Link(), []object{
+			{"aaaa", v0},
+			{"aabb", v1},
+			{"aa", v3},
+			{"b", v2},
+		})
+
+		// values are stored inline, so no other blocks are needed
+		require.Len(t, reachable(t, bs, r0), 3)
+
+		v, err := Get(ctx, bs, r0, "aaaa")
+		require.NoError(t, err)
+		require.Equal(t, v0, v)
+
+		n, err := shard.InlineNode(v)
+		require.NoError(t, err)
+		s, err := n.AsString()
+		require.NoError(t, err)
+		require.Equal(t, "small", s)
+
+		v, err = Get(ctx, bs, r0, "b")
+		require.NoError(t, err)
+		require.Equal(t, v2, v)
+
+		require.Equal(t, []Entry{
+			{"aa", v3},
+			{"aaaa", v0},
+			{"aabb", v1},
+			{"b", v2},
+		}, collectEntries(t, bs, r0))
+
+		r1, diff, err := Del(ctx, bs, r0, "aa")
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+
+		require.Equal(t, []Entry{
+			{"aaaa", v0},
+			{"aabb", v1},
+			{"b", v2},
+		}, collectEntries(t, bs, r1))
+
+		rshard, err := shard.NewFetcher(bs).GetRoot(ctx, r1)
+		require.NoError(t, err)
+		require.Equal(t, int64(64), rshard.Value().MaxInlineSize())
+	})
+
+	t.Run("batch and from entries", func(t *testing.T) {
+		rb0, err := New(shard.WithMaxInlineSize(64))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		var objects []object
+		for i := range 100 {
+			objects = append(objects, object{fmt.Sprintf("key/%02d", i), inlineLink(t, basicnode.NewInt(int64(i)))})
+		}
+		expect := putAll(t, bs, rb0.Link(), objects)
+
+		bbs := testutil.NewBlockstore()
+		err = bbs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		batch, err := NewBatcher(ctx, bbs, rb0.Link())
+		require.NoError(t, err)
+		for _, o := range objects {
+			err = batch.Put(ctx, o.key, o.value)
+			require.NoError(t, err)
+		}
+		root, _, err := batch.Commit()
+		require.NoError(t, err)
+		require.Equal(t, expect.String(), root.String())
+
+		root, err = FromEntries(ctx, objectEntries(objects), func(b shard.BlockView) error {
+			return nil
+		}, shard.WithMaxInlineSize(64))
+		require.NoError(t, err)
+		require.Equal(t, expect.String(), root.String())
+	})
+
+	t.Run("not allowed by default", func(t *testing.T) {
+		rb0, err := New()
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		_, _, err = Put(ctx, bs, rb0.Link(), "key", inlineLink(t, basicnode.NewString("small")))
+		require.ErrorContains(t, err, "inline values are not allowed")
+	})
+
+	t.Run("exceeds max inline size", func(t *testing.T) {
+		rb0, err := New(shard.WithMaxInlineSize(8))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		_, _, err = Put(ctx, bs, rb0.Link(), "key", inlineLink(t, basicnode.NewString("too large to inline")))
+		require.ErrorContains(t, err, "exceeds max inline size of 8 bytes")
+	})
+
+	t.Run("identity links are not inline", func(t *testing.T) {
+		// a link to a list of strings, with an identity multihash
+		digest, err := multihash.Sum([]byte{0x82, 0x61, 0x61, 0x61, 0x62}, multihash.IDENTITY, -1)
+		require.NoError(t, err)
+		value := cidlink.Link{Cid: cid.NewCidV1(uint64(multicodec.DagCbor), digest)}
+		require.False(t, shard.IsInline(value))
+
+		for _, opts := range [][]shard.RootOption{nil, {shard.WithMaxInlineSize(64)}} {
+			rb0, err := New(opts...)
+			require.NoError(t, err)
+
+			bs := testutil.NewBlockstore()
+			err = bs.Put(ctx, rb0)
+			require.NoError(t, err)
+
+			r0, diff, err := Put(ctx, bs, rb0.Link(), "key", value)
+			require.NoError(t, err)
+			testutil.ApplyDiff(t, diff, bs)
+
+			v, err := Get(ctx, bs, r0, "key")
+			require.NoError(t, err)
+			require.Equal(t, value, v)
+			require.False(t, shard.IsInline(v))
+		}
+	})
+}
+
+func inlineLink(t *testing.T, n datamodel.Node) ipld.Link {
+	l, err := shard.InlineLink(n)
+	require.NoError(t, err)
+	return l
+}
@@ -0,0 +1,90 @@
+package testutil
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/stretchr/testify/require"
+)
+
+// Operation is an operation performed on a pail in a conformance test vector.
+type Operation struct {
+	// Type is "put" or "del".
+	Type  string `json:"type"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// ShardFixture is the expected CID and encoded bytes (hex) of a shard.
+type ShardFixture struct {
+	CID   string `json:"cid"`
+	Bytes string `json:"bytes"`
+}
+
+// PailVector is a sequence of operations performed on a new pail, the expected
+// root after each operation and the expected shards of the final pail.
+type PailVector struct {
+	Name       string         `json:"name"`
+	Operations []Operation    `json:"operations"`
+	Roots      []string       `json:"roots"`
+	Shards     []ShardFixture `json:"shards"`
+}
+
+// CRDTResult is the expected result of an operation on a merkle clock
+// backed pail.
+type CRDTResult struct {
+	Root  string   `json:"root"`
+	Head  []string `json:"head"`
+	Event string   `json:"event"`
+}
+
+// CRDTVector is a sequence of operations performed on a merkle clock backed
+// pail with an empty head, and the expected result of each operation.
+type CRDTVector struct {
+	Name       string       `json:"name"`
+	Operations []Operation  `json:"operations"`
+	Results    []CRDTResult `json:"results"`
+}
+
+// Fixtures is the content of a conformance test vector file.
+type Fixtures[V any] struct {
+	// Generator is the implementation and version the fixtures were generated
+	// with.
+	Generator string `json:"generator"`
+	Vectors   []V    `json:"vectors"`
+}
+
+// FixtureGenerator is the prefix of the generator of conformance fixtures,
+// which must be generated from the JavaScript implementation by
+// testdata/conformance/generate.mjs.
+const FixtureGenerator = "@web3-storage/pail@"
+
+// LoadFixtures reads conformance test vectors from a JSON file. The test fails
+// if the file does not exist, i.e. the fixtures have not been generated, or if
+// they were not generated from the JavaScript implementation.
+func LoadFixtures[V any](t *testing.T, path string) Fixtures[V] {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("conformance fixtures not found, run make conformance-fixtures to create %s", path)
+	}
+	require.NoError(t, err)
+	var f Fixtures[V]
+	err = json.Unmarshal(data, &f)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(f.Generator, FixtureGenerator), "fixtures generated by %q, not the JavaScript implementation", f.Generator)
+	require.NotEmpty(t, f.Vectors)
+	return f
+}
+
+// MustParseLink parses a CID string to a link.
+func MustParseLink(s string) ipld.Link {
+	return cidlink.Link{Cid: cid.MustParse(s)}
+}
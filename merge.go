@@ -0,0 +1,116 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/shard"
+)
+
+// Resolver resolves a conflict for a key that was changed differently in both
+// ours and theirs. The base, ours and theirs values are nil if the key does not
+// exist in that pail. It returns the value the key should have in the merged
+// pail, or nil if the key should be deleted.
+type Resolver func(key string, base, ours, theirs ipld.Link) (ipld.Link, error)
+
+// ResolveOurs is a [Resolver] that always picks the value from ours.
+func ResolveOurs(key string, base, ours, theirs ipld.Link) (ipld.Link, error) {
+	return ours, nil
+}
+
+// ResolveTheirs is a [Resolver] that always picks the value from theirs.
+func ResolveTheirs(key string, base, ours, theirs ipld.Link) (ipld.Link, error) {
+	return theirs, nil
+}
+
+// Merge performs a three-way merge of the pails ours and theirs, which were
+// both derived from the pail base. Changes made in theirs are applied to ours,
+// and keys changed differently in both are resolved using the passed resolver.
+//
+// It returns the root of the merged pail and the blocks that were added and
+// removed relative to ours.
+func Merge(ctx context.Context, blocks block.Fetcher, base, ours, theirs ipld.Link, resolver Resolver) (ipld.Link, shard.Diff, error) {
+	batch, err := NewBatcher(ctx, blocks, ours)
+	if err != nil {
+		return nil, shard.Diff{}, fmt.Errorf("creating batch: %w", err)
+	}
+
+	onext, ostop := iter.Pull2(Diff(ctx, blocks, base, ours))
+	defer ostop()
+	tnext, tstop := iter.Pull2(Diff(ctx, blocks, base, theirs))
+	defer tstop()
+
+	// apply sets the key to the value in the merged pail.
+	apply := func(key string, current, value ipld.Link) error {
+		if value == nil {
+			if current == nil {
+				return nil
+			}
+			return batch.Del(ctx, key)
+		}
+		if current != nil && current.String() == value.String() {
+			return nil
+		}
+		return batch.Put(ctx, key, value)
+	}
+
+	ochange, err, ook := onext()
+	if ook && err != nil {
+		return nil, shard.Diff{}, fmt.Errorf("diffing base and ours: %w", err)
+	}
+	tchange, err, tok := tnext()
+	if tok && err != nil {
+		return nil, shard.Diff{}, fmt.Errorf("diffing base and theirs: %w", err)
+	}
+
+	for tok {
+		switch {
+		case ook && ochange.Key < tchange.Key:
+			// changed only in ours
+			ochange, err, ook = onext()
+			if ook && err != nil {
+				return nil, shard.Diff{}, fmt.Errorf("diffing base and ours: %w", err)
+			}
+			continue
+		case ook && ochange.Key == tchange.Key:
+			// changed in both
+			if !sameLink(ochange.New, tchange.New) {
+				value, err := resolver(tchange.Key, tchange.Old, ochange.New, tchange.New)
+				if err != nil {
+					return nil, shard.Diff{}, fmt.Errorf("resolving conflict for key %q: %w", tchange.Key, err)
+				}
+				err = apply(tchange.Key, ochange.New, value)
+				if err != nil {
+					return nil, shard.Diff{}, fmt.Errorf("applying resolved value for key %q: %w", tchange.Key, err)
+				}
+			}
+			ochange, err, ook = onext()
+			if ook && err != nil {
+				return nil, shard.Diff{}, fmt.Errorf("diffing base and ours: %w", err)
+			}
+		default:
+			// changed only in theirs
+			err := apply(tchange.Key, tchange.Old, tchange.New)
+			if err != nil {
+				return nil, shard.Diff{}, fmt.Errorf("applying change for key %q: %w", tchange.Key, err)
+			}
+		}
+
+		tchange, err, tok = tnext()
+		if tok && err != nil {
+			return nil, shard.Diff{}, fmt.Errorf("diffing base and theirs: %w", err)
+		}
+	}
+
+	return batch.Commit()
+}
+
+func sameLink(a, b ipld.Link) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.String() == b.String()
+}
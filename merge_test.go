@@ -0,0 +1,202 @@
+package pail
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("merges non-conflicting changes", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+		v2 := testutil.RandomLink(t)
+		v3 := testutil.RandomLink(t)
+		v4 := testutil.RandomLink(t)
+		base := putRetain(t, bs, rb0.Link(), []object{
+			{"apple", v0},
+			{"banana", v1},
+			{"cherry", v2},
+		})
+
+		ours := putRetain(t, bs, base, []object{{"apricot", v3}})
+		ours = delRetain(t, bs, ours, "banana")
+
+		theirs := putRetain(t, bs, base, []object{
+			{"cherry", v4},
+			{"damson", v4},
+		})
+
+		root, diff, err := Merge(ctx, bs, base, ours, theirs, ResolveOurs)
+		require.NoError(t, err)
+
+		testutil.ApplyDiff(t, diff, bs)
+
+		require.Equal(t, []Entry{
+			{"apple", v0},
+			{"apricot", v3},
+			{"cherry", v4},
+			{"damson", v4},
+		}, collectEntries(t, bs, root))
+	})
+
+	t.Run("resolves conflicting changes", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+		v2 := testutil.RandomLink(t)
+		v3 := testutil.RandomLink(t)
+		base := putRetain(t, bs, rb0.Link(), []object{
+			{"apple", v0},
+			{"banana", v0},
+		})
+
+		ours := putRetain(t, bs, base, []object{{"apple", v1}, {"cherry", v1}})
+		ours = delRetain(t, bs, ours, "banana")
+		theirs := putRetain(t, bs, base, []object{{"apple", v2}, {"banana", v2}, {"cherry", v2}})
+
+		root, _, err := Merge(ctx, bs, base, ours, theirs, ResolveOurs)
+		require.NoError(t, err)
+		require.Equal(t, ours, root)
+
+		root, diff, err := Merge(ctx, bs, base, ours, theirs, ResolveTheirs)
+		require.NoError(t, err)
+		for _, b := range diff.Additions {
+			err = bs.Put(ctx, b)
+			require.NoError(t, err)
+		}
+		require.Equal(t, []Entry{
+			{"apple", v2},
+			{"banana", v2},
+			{"cherry", v2},
+		}, collectEntries(t, bs, root))
+
+		var conflicts []string
+		root, diff, err = Merge(ctx, bs, base, ours, theirs, func(key string, base, ours, theirs ipld.Link) (ipld.Link, error) {
+			conflicts = append(conflicts, key)
+			if key == "apple" {
+				require.Equal(t, v0, base)
+				require.Equal(t, v1, ours)
+				require.Equal(t, v2, theirs)
+				return v3, nil
+			}
+			if key == "banana" {
+				require.Equal(t, v0, base)
+				require.Nil(t, ours)
+				require.Equal(t, v2, theirs)
+				return nil, nil
+			}
+			require.Nil(t, base)
+			return ours, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"apple", "banana", "cherry"}, conflicts)
+		for _, b := range diff.Additions {
+			err = bs.Put(ctx, b)
+			require.NoError(t, err)
+		}
+		require.Equal(t, []Entry{
+			{"apple", v3},
+			{"cherry", v1},
+		}, collectEntries(t, bs, root))
+	})
+
+	t.Run("same change in both is not a conflict", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+		base := putRetain(t, bs, rb0.Link(), []object{{"apple", v0}, {"banana", v0}})
+
+		ours := putRetain(t, bs, base, []object{{"apple", v1}})
+		ours = delRetain(t, bs, ours, "banana")
+		theirs := putRetain(t, bs, base, []object{{"apple", v1}})
+		theirs = delRetain(t, bs, theirs, "banana")
+
+		root, diff, err := Merge(ctx, bs, base, ours, theirs, func(key string, base, ours, theirs ipld.Link) (ipld.Link, error) {
+			return nil, errors.New("unexpected conflict")
+		})
+		require.NoError(t, err)
+		require.Equal(t, ours, root)
+		require.Len(t, diff.Additions, 0)
+		require.Len(t, diff.Removals, 0)
+	})
+
+	t.Run("resolver error", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		base := putRetain(t, bs, rb0.Link(), []object{{"apple", testutil.RandomLink(t)}})
+		ours := putRetain(t, bs, base, []object{{"apple", testutil.RandomLink(t)}})
+		theirs := putRetain(t, bs, base, []object{{"apple", testutil.RandomLink(t)}})
+
+		errConflict := errors.New("conflict")
+		_, _, err = Merge(ctx, bs, base, ours, theirs, func(key string, base, ours, theirs ipld.Link) (ipld.Link, error) {
+			return nil, errConflict
+		})
+		require.ErrorIs(t, err, errConflict)
+	})
+
+	t.Run("shares unchanged shards with ours", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		base := putRetain(t, bs, rb0.Link(), []object{
+			{"aaaa", testutil.RandomLink(t)},
+			{"aabb", testutil.RandomLink(t)},
+			{"bbbb", testutil.RandomLink(t)},
+			{"bbcc", testutil.RandomLink(t)},
+		})
+		ours := putRetain(t, bs, base, []object{{"aacc", testutil.RandomLink(t)}})
+		theirs := putRetain(t, bs, base, []object{{"bbdd", testutil.RandomLink(t)}})
+
+		root, diff, err := Merge(ctx, bs, base, ours, theirs, ResolveOurs)
+		require.NoError(t, err)
+		for _, b := range diff.Additions {
+			err = bs.Put(ctx, b)
+			require.NoError(t, err)
+		}
+
+		for _, l := range reachable(t, bs, root) {
+			if !contains(links(diff.Additions), l) {
+				require.Contains(t, reachable(t, bs, ours), l)
+			}
+		}
+		// only the "b" and "bb" shards and the root are new, the "a" subtree from
+		// ours is unchanged
+		require.Len(t, diff.Additions, 3)
+	})
+}
@@ -0,0 +1,101 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/shard"
+)
+
+// Migrate rewrites every shard of the pail so that it is encoded using the
+// target version (see [shard.SupportedVersions]). The keys and values of the
+// pail, and the other root shard parameters, are unchanged. It returns the
+// link to the new root shard and the diff of shard blocks that were added and
+// removed. If the pail is already of the target version the root is returned
+// with an empty diff.
+func Migrate(ctx context.Context, blocks block.Fetcher, root ipld.Link, version int64) (ipld.Link, shard.Diff, error) {
+	shards := shard.NewFetcher(blocks)
+	rshard, err := shards.GetRoot(ctx, root)
+	if err != nil {
+		return nil, shard.Diff{}, fmt.Errorf("getting root: %w", err)
+	}
+	if !shard.SupportedVersion(version) {
+		return nil, shard.Diff{}, fmt.Errorf("unsupported version: %d", version)
+	}
+	if rshard.Value().Version() == version {
+		return root, shard.Diff{}, nil
+	}
+
+	target := shard.NewRoot(
+		nil,
+		shard.WithVersion(version),
+		shard.WithKeyChars(rshard.Value().KeyChars()),
+		shard.WithMaxKeySize(rshard.Value().MaxKeySize()),
+		shard.WithMaxInlineSize(rshard.Value().MaxInlineSize()),
+		shard.WithMaxShardSize(rshard.Value().MaxShardSize()),
+	)
+
+	var diff shard.Diff
+
+	// migrateEntries migrates the child shards linked from the passed entries
+	// of a shard, returning entries that link to the migrated shards. Shards
+	// that exceed the maximum shard size once migrated are split into parts.
+	var migrateEntries func(fanout bool, entries []shard.Entry) ([]shard.Entry, error)
+	migrateEntries = func(fanout bool, entries []shard.Entry) ([]shard.Entry, error) {
+		var result []shard.Entry
+		for _, e := range entries {
+			if e.Value().Shard() == nil {
+				result = append(result, e)
+				continue
+			}
+			child, err := shards.Get(ctx, e.Value().Shard())
+			if err != nil {
+				return nil, fmt.Errorf("getting shard %s: %w", e.Value().Shard().String(), err)
+			}
+			cfanout := shard.IsFanout(child.Value())
+			ents, err := migrateEntries(cfanout, child.Value().Entries())
+			if err != nil {
+				return nil, err
+			}
+
+			links, parts, err := encodeShard(target, child.Value().Prefix(), cfanout, ents)
+			if err != nil {
+				return nil, err
+			}
+			if len(links) > 1 || links[0].Value().Shard().String() != child.Link().String() {
+				diff.Additions = append(diff.Additions, parts...)
+				diff.Removals = append(diff.Removals, child)
+			}
+			if fanout {
+				// the parts take the place of the child, the first keeping its key
+				links[0] = partEntry(e.Key(), links[0].Value().Shard())
+				result = append(result, links...)
+				continue
+			}
+			link, fanouts, err := joinParts(target, child.Value().Prefix(), links)
+			if err != nil {
+				return nil, err
+			}
+			diff.Additions = append(diff.Additions, fanouts...)
+			result = append(result, shard.NewEntry(e.Key(), shard.NewValue(e.Value().Value(), link)))
+		}
+		return result, nil
+	}
+
+	fanout := shard.IsFanout(rshard.Value())
+	entries, err := migrateEntries(fanout, rshard.Value().Entries())
+	if err != nil {
+		return nil, shard.Diff{}, err
+	}
+
+	rblocks, err := encodeRoot(target, fanout, entries)
+	if err != nil {
+		return nil, shard.Diff{}, fmt.Errorf("marshalling root shard: %w", err)
+	}
+	diff.Additions = append(diff.Additions, rblocks...)
+	diff.Removals = append(diff.Removals, shard.AsBlock(rshard))
+
+	return rblocks[len(rblocks)-1].Link(), diff, nil
+}
@@ -0,0 +1,147 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+
+	var objects []object
+	for i := range 100 {
+		objects = append(objects, object{fmt.Sprintf("dir%d/file%02d.txt", i%3, i), testutil.RandomLink(t)})
+	}
+
+	t.Run("migrate to version 3 and back", func(t *testing.T) {
+		rb0, err := New()
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := putAll(t, bs, rb0.Link(), objects)
+		entries := collectEntries(t, bs, r0)
+
+		r1, diff, err := Migrate(ctx, bs, r0, shard.Version3)
+		require.NoError(t, err)
+		require.Len(t, diff.Additions, len(reachable(t, bs, r0)))
+		require.Len(t, diff.Removals, len(diff.Additions))
+		// every shard is rewritten in the target version
+		for _, b := range diff.Additions {
+			require.Equal(t, int64(shard.Version3), shard.VersionOf(b.Value()))
+
+			s, err := shard.Unmarshal(b.Bytes())
+			require.NoError(t, err)
+			require.Equal(t, int64(shard.Version3), shard.VersionOf(s))
+		}
+		testutil.ApplyDiff(t, diff, bs)
+
+		require.Equal(t, entries, collectEntries(t, bs, r1))
+
+		rshard, err := shard.NewFetcher(bs).GetRoot(ctx, r1)
+		require.NoError(t, err)
+		require.Equal(t, int64(shard.Version3), rshard.Value().Version())
+
+		// migrating back to version 2 results in the original pail
+		r2, _, err := Migrate(ctx, bs, r1, shard.Version2)
+		require.NoError(t, err)
+		require.Equal(t, r0.String(), r2.String())
+
+		// the version is retained when the pail is modified
+		v := testutil.RandomLink(t)
+		r3, diff, err := Put(ctx, bs, r1, "dir0/new.txt", v)
+		require.NoError(t, err)
+		for _, b := range diff.Additions {
+			require.Equal(t, int64(shard.Version3), shard.VersionOf(b.Value()))
+		}
+		testutil.ApplyDiff(t, diff, bs)
+
+		v0, err := Get(ctx, bs, r3, "dir0/new.txt")
+		require.NoError(t, err)
+		require.Equal(t, v, v0)
+	})
+
+	t.Run("split pail", func(t *testing.T) {
+		rb0, err := New(shard.WithMaxShardSize(256))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := putAll(t, bs, rb0.Link(), objects)
+		require.Greater(t, countFanouts(t, bs, r0), 0)
+		entries := collectEntries(t, bs, r0)
+
+		// shards that grow when the version is encoded are split again
+		r1, diff, err := Migrate(ctx, bs, r0, shard.Version3)
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+		require.LessOrEqual(t, maxShardBytes(t, bs, r1), 256)
+		require.Equal(t, entries, collectEntries(t, bs, r1))
+
+		problems, err := Verify(ctx, bs, r1)
+		require.NoError(t, err)
+		require.Empty(t, problems)
+	})
+
+	t.Run("same as a pail created with version 3", func(t *testing.T) {
+		rb0, err := New()
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := putAll(t, bs, rb0.Link(), objects)
+		r1, diff, err := Migrate(ctx, bs, r0, shard.Version3)
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+
+		rb3, err := New(shard.WithVersion(shard.Version3))
+		require.NoError(t, err)
+		err = bs.Put(ctx, rb3)
+		require.NoError(t, err)
+
+		expect := putAll(t, bs, rb3.Link(), objects)
+		require.Equal(t, expect.String(), r1.String())
+	})
+
+	t.Run("already target version", func(t *testing.T) {
+		rb0, err := New()
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := putAll(t, bs, rb0.Link(), objects)
+		r1, diff, err := Migrate(ctx, bs, r0, shard.Version2)
+		require.NoError(t, err)
+		require.Equal(t, r0.String(), r1.String())
+		require.Empty(t, diff.Additions)
+		require.Empty(t, diff.Removals)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		rb0, err := New()
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		_, _, err = Migrate(ctx, bs, rb0.Link(), 1)
+		require.ErrorContains(t, err, "unsupported version: 1")
+
+		_, err = New(shard.WithVersion(4))
+		require.ErrorContains(t, err, "unsupported version: 4")
+	})
+}
@@ -0,0 +1,131 @@
+package pail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/shard"
+)
+
+var ErrInvalidProof = errors.New("invalid proof")
+
+// Proof proves that a key is, or is not, in a pail.
+type Proof struct {
+	// Blocks are the shard blocks on the lookup path of the key, starting with
+	// the root shard and ending with the shard that contains the key, or would
+	// contain it if it were in the pail.
+	Blocks []block.Block
+}
+
+// Prove creates a proof that the key is, or is not, in the pail. The proof
+// consists of the minimal set of shard blocks needed to look up the key and
+// can be checked against the root using [VerifyProof].
+func Prove(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string) (Proof, error) {
+	shards := shard.NewFetcher(blocks)
+	rshard, err := shards.GetRoot(ctx, root)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	path, err := traverse(ctx, shards, shard.AsBlock(rshard), key)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	var proof Proof
+	for _, s := range path {
+		proof.Blocks = append(proof.Blocks, block.New(s.Link(), s.Bytes()))
+	}
+	return proof, nil
+}
+
+// VerifyProof checks the proof for the key against the root of a pail, without
+// access to a blockstore. The blocks of the proof must hash to their CIDs and
+// be canonically encoded shards that form the lookup path of the key from the
+// root.
+//
+// If the proof shows the key is in the pail, the value is returned. If it
+// shows the key is not in the pail, [ErrNotFound] is returned. Otherwise the
+// returned error wraps [ErrInvalidProof].
+func VerifyProof(root ipld.Link, key string, proof Proof) (ipld.Link, error) {
+	if len(proof.Blocks) == 0 {
+		return nil, fmt.Errorf("%w: no blocks", ErrInvalidProof)
+	}
+
+	b := proof.Blocks[0]
+	if b.Link().String() != root.String() {
+		return nil, fmt.Errorf("%w: first block is not the root %s", ErrInvalidProof, root.String())
+	}
+	err := checkHash(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProof, err)
+	}
+	rshard, err := shard.UnmarshalRoot(b.Bytes(), shard.WithStrict())
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding root shard: %w", ErrInvalidProof, err)
+	}
+
+	var s shard.Shard = rshard
+	skey := key // key within the shard
+	for i := 1; ; i++ {
+		var next ipld.Link
+		if shard.IsFanout(s) {
+			// parts have the same prefix as the fanout shard
+			next = s.Entries()[route(s.Entries(), skey)].Value().Shard()
+		} else {
+			for _, e := range s.Entries() {
+				if skey == e.Key() {
+					break
+				}
+				if strings.HasPrefix(skey, e.Key()) && e.Value().Shard() != nil {
+					next = e.Value().Shard()
+					skey = skey[len(e.Key()):]
+					break
+				}
+			}
+		}
+		if next == nil {
+			if i != len(proof.Blocks) {
+				return nil, fmt.Errorf("%w: %d unused blocks", ErrInvalidProof, len(proof.Blocks)-i)
+			}
+			break
+		}
+
+		if i == len(proof.Blocks) {
+			return nil, fmt.Errorf("%w: missing shard %s", ErrInvalidProof, next.String())
+		}
+		b := proof.Blocks[i]
+		if b.Link().String() != next.String() {
+			return nil, fmt.Errorf("%w: expected shard %s but found %s", ErrInvalidProof, next.String(), b.Link().String())
+		}
+		err := checkHash(b)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidProof, err)
+		}
+		s, err = shard.Unmarshal(b.Bytes(),
+			shard.WithStrict(),
+			shard.WithPailMaxKeySize(rshard.MaxKeySize()),
+			shard.WithPailMaxInlineSize(rshard.MaxInlineSize()),
+			shard.WithPailMaxShardSize(rshard.MaxShardSize()),
+			shard.WithPailVersion(rshard.Version()),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%w: decoding shard %s: %w", ErrInvalidProof, b.Link().String(), err)
+		}
+		if prefix := key[:len(key)-len(skey)]; s.Prefix() != prefix {
+			return nil, fmt.Errorf("%w: shard %s has prefix %q, expected %q", ErrInvalidProof, b.Link().String(), s.Prefix(), prefix)
+		}
+	}
+
+	for _, e := range s.Entries() {
+		// an entry may be a link to a shard without a value
+		if e.Key() == skey && e.Value().Value() != nil {
+			return e.Value().Value(), nil
+		}
+	}
+	return nil, ErrNotFound
+}
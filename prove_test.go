@@ -0,0 +1,110 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProve(t *testing.T) {
+	ctx := context.Background()
+
+	rb0, err := New()
+	require.NoError(t, err)
+
+	bs := testutil.NewBlockstore()
+	err = bs.Put(ctx, rb0)
+	require.NoError(t, err)
+
+	var objects []object
+	for i := range 100 {
+		objects = append(objects, object{fmt.Sprintf("dir%d/file%02d.txt", i%3, i), testutil.RandomLink(t)})
+	}
+	objects = append(objects, object{"dir", testutil.RandomLink(t)})
+	root := putAll(t, bs, rb0.Link(), objects)
+
+	t.Run("inclusion", func(t *testing.T) {
+		for _, o := range objects {
+			proof, err := Prove(ctx, bs, root, o.key)
+			require.NoError(t, err)
+
+			v, err := VerifyProof(root, o.key, proof)
+			require.NoError(t, err)
+			require.Equal(t, o.value, v)
+		}
+	})
+
+	t.Run("minimal", func(t *testing.T) {
+		proof, err := Prove(ctx, bs, root, objects[0].key)
+		require.NoError(t, err)
+		require.Greater(t, len(proof.Blocks), 1)
+		require.Less(t, len(proof.Blocks), len(reachable(t, bs, root)))
+		require.Equal(t, root.String(), proof.Blocks[0].Link().String())
+	})
+
+	t.Run("exclusion", func(t *testing.T) {
+		// not found in a leaf shard, a shard link without a value, and the root
+		for _, key := range []string{"dir0/file98.txt", "dir0/", "dir0/file0", "missing"} {
+			proof, err := Prove(ctx, bs, root, key)
+			require.NoError(t, err)
+
+			_, err = VerifyProof(root, key, proof)
+			require.ErrorIs(t, err, ErrNotFound, key)
+		}
+	})
+
+	t.Run("invalid proofs", func(t *testing.T) {
+		key := objects[0].key
+		proof, err := Prove(ctx, bs, root, key)
+		require.NoError(t, err)
+
+		other, err := Prove(ctx, bs, root, objects[1].key)
+		require.NoError(t, err)
+
+		last := len(proof.Blocks) - 1
+		tampered := append([]block.Block{}, proof.Blocks...)
+		tampered[last] = block.New(proof.Blocks[last].Link(), other.Blocks[len(other.Blocks)-1].Bytes())
+
+		vectors := []struct {
+			name  string
+			root  string
+			proof Proof
+		}{
+			{"empty", root.String(), Proof{}},
+			{"wrong root", rb0.Link().String(), proof},
+			{"missing block", root.String(), Proof{Blocks: proof.Blocks[:last]}},
+			{"extra block", root.String(), Proof{Blocks: append(append([]block.Block{}, proof.Blocks...), rb0)}},
+			{"block does not match CID", root.String(), Proof{Blocks: tampered}},
+			{"proof for another key", root.String(), other},
+		}
+		for _, v := range vectors {
+			t.Run(v.name, func(t *testing.T) {
+				_, err := VerifyProof(testutil.MustParseLink(v.root), key, v.proof)
+				require.ErrorIs(t, err, ErrInvalidProof)
+			})
+		}
+	})
+
+	t.Run("binary keys", func(t *testing.T) {
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsBinary))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		v0 := testutil.RandomLink(t)
+		root := putAll(t, bs, rb0.Link(), []object{{"\xc3\xa4", v0}, {"\xc3\xa5", testutil.RandomLink(t)}})
+
+		proof, err := Prove(ctx, bs, root, "\xc3\xa4")
+		require.NoError(t, err)
+		v, err := VerifyProof(root, "\xc3\xa4", proof)
+		require.NoError(t, err)
+		require.Equal(t, v0, v)
+	})
+}
@@ -6,14 +6,24 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/ipld/go-ipld-prime"
 	"github.com/storacha/go-pail/block"
 	"github.com/storacha/go-pail/shard"
 )
 
+var ErrConflict = errors.New("conflict")
+
 // Put a value (a CID) for the given key. If the key exists it's value is
 // overwritten.
+//
+// If the value is an inline link (see [shard.InlineLink]) the data it contains
+// is stored inline in the shard, which requires the pail to be created with a
+// maximum inline size (see [shard.WithMaxInlineSize]).
+//
+// In a pail with the [shard.KeyCharsBinary] key character set the key is a
+// string of raw bytes, see [PutBytes].
 func Put(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string, value ipld.Link) (ipld.Link, shard.Diff, error) {
 	shards := shard.NewFetcher(blocks)
 	rshard, err := shards.GetRoot(ctx, root)
@@ -21,14 +31,13 @@ func Put(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string,
 		return nil, shard.Diff{}, err
 	}
 
-	if rshard.Value().KeyChars() != shard.KeyCharsASCII {
-		return nil, shard.Diff{}, fmt.Errorf("unsupported key character set: %s", rshard.Value().KeyChars())
-	}
-	if !shard.IsPrintableASCII(key) {
-		return nil, shard.Diff{}, errors.New("key contains non-ASCII characters")
+	err = validateKey(rshard.Value(), key)
+	if err != nil {
+		return nil, shard.Diff{}, err
 	}
-	if int64(len(key)) > rshard.Value().MaxKeySize() {
-		return nil, shard.Diff{}, fmt.Errorf("UTF-8 encoded key exceeds max size of %d bytes", rshard.Value().MaxKeySize())
+	err = validateValue(rshard.Value(), value)
+	if err != nil {
+		return nil, shard.Diff{}, err
 	}
 
 	path, err := traverse(ctx, shards, shard.AsBlock(rshard), key)
@@ -39,7 +48,7 @@ func Put(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string,
 	skey := key[len(target.Value().Prefix()):]
 
 	entry := shard.NewEntry(skey, shard.NewValue(value, nil))
-	targetEntries := target.Value().Entries()[:]
+	targetEntries := slices.Clone(target.Value().Entries())
 
 	var additions []shard.BlockView
 	for i, e := range targetEntries {
@@ -62,14 +71,7 @@ func Put(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string,
 			longest = k
 		}
 
-		common := ""
-		for _, char := range shortest {
-			next := common + string(char)
-			if !strings.HasPrefix(longest, next) {
-				break
-			}
-			common = next
-		}
+		common := shortest[:commonPrefixLen(rshard.Value(), shortest, longest)]
 		if common != "" {
 			var entries []shard.Entry
 
@@ -86,17 +88,14 @@ func Put(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string,
 				entries = shard.PutEntry(entries, shard.NewEntry(k[len(common):], v))
 			}
 
-			child, err := shard.MarshalBlock(shard.New(target.Value().Prefix()+common, entries))
+			child, err := shard.MarshalBlock(newShard(rshard.Value(), target.Value().Prefix()+common, entries))
 			if err != nil {
 				return nil, shard.Diff{}, err
 			}
 			additions = append(additions, child)
 
 			// create parent shards for each character of the common prefix
-			var commonChars []string
-			for _, c := range common {
-				commonChars = append(commonChars, string(c))
-			}
+			commonChars := splitKey(rshard.Value(), common)
 			for i := len(commonChars) - 1; i > 0; i-- {
 				parentPrefix := target.Value().Prefix() + strings.Join(commonChars[0:i], "")
 				var parentValue shard.Value
@@ -115,7 +114,8 @@ func Put(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string,
 				}
 
 				parent, err := shard.MarshalBlock(
-					shard.New(
+					newShard(
+						rshard.Value(),
 						parentPrefix,
 						[]shard.Entry{shard.NewEntry(commonChars[i], parentValue)},
 					),
@@ -142,54 +142,251 @@ func Put(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string,
 		}
 	}
 
-	var nshard shard.Shard
-	if target.Value().Prefix() == "" {
-		nshard = shard.NewRoot(shard.PutEntry(targetEntries, entry))
-	} else {
-		nshard = shard.New(target.Value().Prefix(), shard.PutEntry(targetEntries, entry))
-	}
-
-	child, err := shard.MarshalBlock(nshard)
+	children, err := propagate(rshard.Value(), path, shard.PutEntry(targetEntries, entry))
 	if err != nil {
 		return nil, shard.Diff{}, err
 	}
 
 	// if no change in the target then we're done
-	if child.Link().String() == target.Link().String() {
+	nroot := children[len(children)-1]
+	if nroot.Link().String() == root.String() {
 		return root, shard.Diff{}, nil
 	}
 
-	additions = append(additions, child)
+	additions = append(additions, children...)
+	return nroot.Link(), newDiff(additions, path), nil
+}
 
+// newDiff creates a diff of the added and removed shards, leaving out shards
+// that were both added and removed. A part of a shard that was split may be
+// identical to the shard it was split from.
+func newDiff(additions []shard.BlockView, removals []shard.BlockView) shard.Diff {
+	added := map[string]struct{}{}
+	for _, b := range additions {
+		added[b.Link().String()] = struct{}{}
+	}
+	removed := map[string]struct{}{}
+	for _, b := range removals {
+		removed[b.Link().String()] = struct{}{}
+	}
+	unchanged := func(b shard.BlockView) bool {
+		_, ok := added[b.Link().String()]
+		_, rok := removed[b.Link().String()]
+		return ok && rok
+	}
+	return shard.Diff{
+		Additions: slices.DeleteFunc(slices.Clone(additions), unchanged),
+		Removals:  slices.DeleteFunc(slices.Clone(removals), unchanged),
+	}
+}
+
+// propagate encodes the last shard in the path with the passed entries, and
+// each of its ancestors with the link to it updated. A shard that exceeds the
+// maximum shard size is split into parts (see [encodeShard]), which take its
+// place in a fanout parent, or are linked from a new fanout shard otherwise.
+//
+// It returns the blocks that were created, children before parents, so the
+// last block is the new root shard.
+func propagate(rshard shard.RootShard, path []shard.BlockView, entries []shard.Entry) ([]shard.BlockView, error) {
+	var additions []shard.BlockView
 	// path is root -> target, so work backwards, propagating the new shard CID
-	for i := len(path) - 2; i >= 0; i-- {
-		parent := path[i]
-		key := child.Value().Prefix()[len(parent.Value().Prefix()):]
-
-		entries := parent.Value().Entries()[:]
-		for i, e := range entries {
-			if e.Key() == key {
-				if e.Value().Shard() == nil {
-					return nil, shard.Diff{}, fmt.Errorf("\"%s\" is not a shard link in: %s", key, parent.Link().String())
-				}
-				entries[i] = shard.NewEntry(key, shard.NewValue(e.Value().Value(), child.Link()))
-				break
-			}
+	for i := len(path) - 1; i > 0; i-- {
+		child := path[i]
+		links, blocks, err := encodeShard(rshard, child.Value().Prefix(), shard.IsFanout(child.Value()), entries)
+		if err != nil {
+			return nil, err
 		}
+		additions = append(additions, blocks...)
 
-		var cshard shard.Shard
-		if parent.Value().Prefix() == "" {
-			cshard = shard.NewRoot(entries)
-		} else {
-			cshard = shard.New(parent.Value().Prefix(), entries)
+		parent := path[i-1]
+		entries = slices.Clone(parent.Value().Entries())
+		idx := slices.IndexFunc(entries, func(e shard.Entry) bool {
+			return e.Value().Shard() != nil && e.Value().Shard().String() == child.Link().String()
+		})
+		if idx == -1 { // should not happen!
+			return nil, fmt.Errorf("did not find link to %s in parent: %s", child.Link().String(), parent.Link().String())
+		}
+
+		if shard.IsFanout(parent.Value()) {
+			// the parts take the place of the child, the first keeping its key
+			links[0] = partEntry(entries[idx].Key(), links[0].Value().Shard())
+			entries = slices.Replace(entries, idx, idx+1, links...)
+			continue
 		}
 
-		child, err = shard.MarshalBlock(cshard)
+		link, blocks, err := joinParts(rshard, child.Value().Prefix(), links)
 		if err != nil {
-			return nil, shard.Diff{}, err
+			return nil, err
 		}
-		additions = append(additions, child)
+		additions = append(additions, blocks...)
+		entries[idx] = shard.NewEntry(entries[idx].Key(), shard.NewValue(entries[idx].Value().Value(), link))
 	}
 
-	return additions[len(additions)-1].Link(), shard.Diff{Additions: additions, Removals: path}, nil
+	// a fanout root shard without parts is an empty root shard
+	blocks, err := encodeRoot(rshard, shard.IsFanout(path[0].Value()) && len(entries) > 0, entries)
+	if err != nil {
+		return nil, err
+	}
+	return append(additions, blocks...), nil
+}
+
+// PutIf puts a value (a CID) for the given key, only if the current value for
+// the key is equal to the expected value. An expected value of nil means the
+// key must not exist. If the current value is not the expected value
+// [ErrConflict] is returned as the error value.
+func PutIf(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string, expected ipld.Link, value ipld.Link) (ipld.Link, shard.Diff, error) {
+	err := checkCurrent(ctx, blocks, root, key, expected)
+	if err != nil {
+		return nil, shard.Diff{}, err
+	}
+	return Put(ctx, blocks, root, key, value)
+}
+
+// checkCurrent returns [ErrConflict] if the current value for the key is not
+// equal to the expected value.
+func checkCurrent(ctx context.Context, blocks block.Fetcher, root ipld.Link, key string, expected ipld.Link) error {
+	current, err := Get(ctx, blocks, root, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("getting current value: %w", err)
+	}
+	if current == nil && expected == nil {
+		return nil
+	}
+	if current == nil {
+		return fmt.Errorf("%w: expected %s for key %q but it does not exist", ErrConflict, expected, key)
+	}
+	if expected == nil {
+		return fmt.Errorf("%w: expected key %q to not exist but found %s", ErrConflict, key, current)
+	}
+	if current.String() != expected.String() {
+		return fmt.Errorf("%w: expected %s for key %q but found %s", ErrConflict, expected, key, current)
+	}
+	return nil
+}
+
+// validateKey checks the key is acceptable for storage in a pail with the
+// passed root shard.
+func validateKey(rshard shard.RootShard, key string) error {
+	switch rshard.KeyChars() {
+	case shard.KeyCharsASCII:
+		if !shard.IsPrintableASCII(key) {
+			return errors.New("key contains non-ASCII characters")
+		}
+	case shard.KeyCharsUTF8:
+		if !utf8.ValidString(key) {
+			return errors.New("key is not valid UTF-8")
+		}
+	case shard.KeyCharsBinary:
+		// any byte string is a valid key
+	default:
+		return fmt.Errorf("unsupported key character set: %s", rshard.KeyChars())
+	}
+	if int64(len(key)) > rshard.MaxKeySize() {
+		return fmt.Errorf("UTF-8 encoded key exceeds max size of %d bytes", rshard.MaxKeySize())
+	}
+	return nil
+}
+
+// validateRoot checks the parameters of the passed root shard are supported.
+func validateRoot(rshard shard.RootShard) error {
+	if !shard.SupportedVersion(rshard.Version()) {
+		return fmt.Errorf("unsupported version: %d", rshard.Version())
+	}
+	switch rshard.KeyChars() {
+	case shard.KeyCharsASCII, shard.KeyCharsUTF8, shard.KeyCharsBinary:
+	default:
+		return fmt.Errorf("unsupported key character set: %s", rshard.KeyChars())
+	}
+	if rshard.MaxKeySize() < 1 {
+		return fmt.Errorf("invalid maximum key size: %d", rshard.MaxKeySize())
+	}
+	if rshard.MaxInlineSize() < 0 {
+		return fmt.Errorf("invalid maximum inline size: %d", rshard.MaxInlineSize())
+	}
+	if rshard.MaxShardSize() < 0 {
+		return fmt.Errorf("invalid maximum shard size: %d", rshard.MaxShardSize())
+	}
+	return nil
+}
+
+// validateValue checks the value is acceptable for storage in a pail with the
+// passed root shard. Inline values must not exceed the maximum inline size.
+func validateValue(rshard shard.RootShard, value ipld.Link) error {
+	if !shard.IsInline(value) {
+		return nil
+	}
+	if rshard.MaxInlineSize() == 0 {
+		return errors.New("inline values are not allowed")
+	}
+	size, err := shard.InlineSize(value)
+	if err != nil {
+		return err
+	}
+	if int64(size) > rshard.MaxInlineSize() {
+		return fmt.Errorf("inline value exceeds max inline size of %d bytes", rshard.MaxInlineSize())
+	}
+	return nil
+}
+
+// newRoot creates a new root shard with the passed entries, retaining the
+// parameters of the passed existing root shard.
+func newRoot(rshard shard.RootShard, entries []shard.Entry, opts ...shard.RootOption) shard.RootShard {
+	return shard.NewRoot(
+		entries,
+		append([]shard.RootOption{
+			shard.WithVersion(rshard.Version()),
+			shard.WithKeyChars(rshard.KeyChars()),
+			shard.WithMaxKeySize(rshard.MaxKeySize()),
+			shard.WithMaxInlineSize(rshard.MaxInlineSize()),
+			shard.WithMaxShardSize(rshard.MaxShardSize()),
+		}, opts...)...,
+	)
+}
+
+// newShard creates a new non-root shard with the passed prefix and entries,
+// for a pail with the passed root shard. The prefix and keys are encoded as
+// bytes in pails with binary keys, and as text otherwise.
+func newShard(rshard shard.RootShard, prefix string, entries []shard.Entry, opts ...shard.Option) shard.Shard {
+	opts = append([]shard.Option{shard.WithShardVersion(rshard.Version())}, opts...)
+	if rshard.KeyChars() == shard.KeyCharsBinary {
+		return shard.NewBinary(prefix, entries, opts...)
+	}
+	return shard.New(prefix, entries, opts...)
+}
+
+// splitKey splits a key into the characters that shard prefixes are formed
+// from. These are bytes for pails with binary keys, otherwise they are runes,
+// so that multi-byte characters are never split across shards.
+func splitKey(rshard shard.RootShard, key string) []string {
+	var chars []string
+	for i := 0; i < len(key); {
+		size := charLen(rshard, key[i:])
+		chars = append(chars, key[i:i+size])
+		i += size
+	}
+	return chars
+}
+
+// charLen returns the length in bytes of the first character of the key, as
+// returned by [splitKey].
+func charLen(rshard shard.RootShard, key string) int {
+	if rshard.KeyChars() == shard.KeyCharsBinary {
+		return 1
+	}
+	_, size := utf8.DecodeRuneInString(key)
+	return size
+}
+
+// commonPrefixLen returns the length in bytes of the common prefix of the two
+// keys, made up of whole characters as returned by [splitKey].
+func commonPrefixLen(rshard shard.RootShard, a, b string) int {
+	var n int
+	for n < len(a) {
+		size := charLen(rshard, a[n:])
+		if !strings.HasPrefix(b[n:], a[n:n+size]) {
+			break
+		}
+		n += size
+	}
+	return n
 }
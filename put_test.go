@@ -147,6 +147,132 @@ func TestPut(t *testing.T) {
 		}, materialize(t, bs, r0))
 	})
 
+	t.Run("UTF-8 keys", func(t *testing.T) {
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsUTF8))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+		v2 := testutil.RandomLink(t)
+		// "ä" and "å" share the same first byte, so must not be split there
+		r0 := putAll(t, bs, rb0.Link(), []object{
+			{"ä/日本", v0},
+			{"å/日本", v1},
+			{"ä/日x", v2},
+		})
+
+		require.Equal(t, []entry{
+			{
+				"ä",
+				value{
+					nil,
+					[]entry{
+						{
+							"/",
+							value{
+								nil,
+								[]entry{
+									{
+										"日",
+										value{
+											nil,
+											[]entry{
+												{"x", value{v2, nil}},
+												{"本", value{v0, nil}},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			{"å/日本", value{v1, nil}},
+		}, materialize(t, bs, r0))
+
+		rshard, err := shard.NewFetcher(bs).GetRoot(ctx, r0)
+		require.NoError(t, err)
+		require.Equal(t, shard.KeyCharsUTF8, rshard.Value().KeyChars())
+
+		for _, o := range []object{{"ä/日本", v0}, {"å/日本", v1}, {"ä/日x", v2}} {
+			v, err := Get(ctx, bs, r0, o.key)
+			require.NoError(t, err)
+			require.Equal(t, o.value, v)
+		}
+	})
+
+	t.Run("rejects invalid UTF-8 keys", func(t *testing.T) {
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsUTF8))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		_, _, err = Put(ctx, bs, rb0.Link(), "invalid\xc3", testutil.RandomLink(t))
+		require.ErrorContains(t, err, "not valid UTF-8")
+	})
+
+	t.Run("rejects UTF-8 keys in ASCII pail", func(t *testing.T) {
+		rb0, err := New()
+		require.NoError(t, err)
+		require.Equal(t, shard.KeyCharsASCII, rb0.Value().KeyChars())
+		require.Equal(t, "bafyreiesj77bspnvajezltkvavgngyve7pucqcx527s42jzmo66tdewg44", rb0.Link().String())
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		_, _, err = Put(ctx, bs, rb0.Link(), "日本", testutil.RandomLink(t))
+		require.ErrorContains(t, err, "non-ASCII")
+	})
+
+	t.Run("max key size", func(t *testing.T) {
+		rb0, err := New(shard.WithMaxKeySize(8))
+		require.NoError(t, err)
+		require.Equal(t, int64(8), rb0.Value().MaxKeySize())
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		_, _, err = Put(ctx, bs, rb0.Link(), "too-long-key", testutil.RandomLink(t))
+		require.ErrorContains(t, err, "exceeds max size of 8 bytes")
+
+		r0 := putAll(t, bs, rb0.Link(), []object{
+			{"aaaaaaaa", testutil.RandomLink(t)},
+			{"aaaabbbb", testutil.RandomLink(t)},
+		})
+		rshard, err := shard.NewFetcher(bs).GetRoot(ctx, r0)
+		require.NoError(t, err)
+		require.Equal(t, int64(8), rshard.Value().MaxKeySize())
+
+		r1, diff, err := Del(ctx, bs, r0, "aaaabbbb")
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+
+		// root shard parameters are retained by put and delete
+		rshard, err = shard.NewFetcher(bs).GetRoot(ctx, r1)
+		require.NoError(t, err)
+		require.Equal(t, int64(8), rshard.Value().MaxKeySize())
+
+		_, _, err = Put(ctx, bs, r1, "too-long-key", testutil.RandomLink(t))
+		require.ErrorContains(t, err, "exceeds max size of 8 bytes")
+	})
+
+	t.Run("invalid root shard options", func(t *testing.T) {
+		_, err := New(shard.WithMaxKeySize(0))
+		require.ErrorContains(t, err, "invalid maximum key size")
+
+		_, err = New(shard.WithKeyChars("ebcdic"))
+		require.ErrorContains(t, err, "unsupported key character set")
+	})
+
 	t.Run("deterministic structure", func(t *testing.T) {
 		v0 := testutil.RandomLink(t)
 		v1 := testutil.RandomLink(t)
@@ -268,6 +394,16 @@ func materialize(t *testing.T, blocks block.Fetcher, root ipld.Link) []entry {
 	return entries
 }
 
+// collectEntries lists the entries of the pail in key order.
+func collectEntries(t *testing.T, blocks block.Fetcher, root ipld.Link) []Entry {
+	var results []Entry
+	for e, err := range Entries(context.Background(), blocks, root) {
+		require.NoError(t, err)
+		results = append(results, e)
+	}
+	return results
+}
+
 func putAll(t *testing.T, bs testutil.Blockstore, root ipld.Link, objects []object) ipld.Link {
 	ctx := context.Background()
 	for _, o := range objects {
@@ -278,3 +414,65 @@ func putAll(t *testing.T, bs testutil.Blockstore, root ipld.Link, objects []obje
 	}
 	return root
 }
+
+func TestPutIf(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("put if key does not exist", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := rb0.Link()
+		k0 := "test"
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+
+		r1, diff0, err := PutIf(ctx, bs, r0, k0, nil, v0)
+		require.NoError(t, err)
+
+		testutil.ApplyDiff(t, diff0, bs)
+
+		o0, err := Get(ctx, bs, r1, k0)
+		require.NoError(t, err)
+		require.Equal(t, v0, o0)
+
+		_, _, err = PutIf(ctx, bs, r1, k0, nil, v1)
+		require.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("put if value is expected value", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		k0 := "aaaa"
+		v0 := testutil.RandomLink(t)
+		v1 := testutil.RandomLink(t)
+		v2 := testutil.RandomLink(t)
+		r0 := putAll(t, bs, rb0.Link(), []object{{k0, v0}, {"aabb", v0}})
+
+		r1, diff0, err := PutIf(ctx, bs, r0, k0, v0, v1)
+		require.NoError(t, err)
+
+		testutil.ApplyDiff(t, diff0, bs)
+
+		o0, err := Get(ctx, bs, r1, k0)
+		require.NoError(t, err)
+		require.Equal(t, v1, o0)
+
+		// stale expected value
+		_, _, err = PutIf(ctx, bs, r1, k0, v0, v2)
+		require.ErrorIs(t, err, ErrConflict)
+
+		// key does not exist
+		_, _, err = PutIf(ctx, bs, r1, "missing", v0, v2)
+		require.ErrorIs(t, err, ErrConflict)
+	})
+}
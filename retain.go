@@ -0,0 +1,64 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/shard"
+)
+
+// Retain walks the shards of the pails with the passed roots and returns a
+// function that reports whether a block is one of those shards. It can be
+// passed to [block.Apply] using [block.WithRetain], so that applying a diff
+// to a blockstore shared by several pails never deletes a shard that is still
+// referenced by another tracked root.
+func Retain(ctx context.Context, blocks block.Fetcher, roots ...ipld.Link) (block.RetainFunc, error) {
+	shards := shard.NewFetcher(blocks)
+	links := map[string]struct{}{}
+
+	var walk func(entries []shard.Entry) error
+	walk = func(entries []shard.Entry) error {
+		for _, e := range entries {
+			l := e.Value().Shard()
+			if l == nil {
+				continue
+			}
+			// shards are shared between pails with common history
+			if _, ok := links[l.String()]; ok {
+				continue
+			}
+			s, err := shards.Get(ctx, l)
+			if err != nil {
+				return fmt.Errorf("getting shard %s: %w", l.String(), err)
+			}
+			links[l.String()] = struct{}{}
+			err = walk(s.Value().Entries())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, root := range roots {
+		if _, ok := links[root.String()]; ok {
+			continue
+		}
+		rshard, err := shards.GetRoot(ctx, root)
+		if err != nil {
+			return nil, fmt.Errorf("getting root %s: %w", root.String(), err)
+		}
+		links[root.String()] = struct{}{}
+		err = walk(rshard.Value().Entries())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(ctx context.Context, link ipld.Link) (bool, error) {
+		_, ok := links[link.String()]
+		return ok, nil
+	}, nil
+}
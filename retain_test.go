@@ -0,0 +1,66 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetain(t *testing.T) {
+	ctx := context.Background()
+
+	var objects []object
+	for i := range 100 {
+		objects = append(objects, object{fmt.Sprintf("dir%d/file%03d.txt", i%5, i), testutil.RandomLink(t)})
+	}
+
+	// setup creates two pails with common history that share a blockstore,
+	// returning the diff that creates the second from the first.
+	setup := func(t *testing.T) (*block.MapBlockstore, ipld.Link, ipld.Link, shard.Diff) {
+		rb0, err := New()
+		require.NoError(t, err)
+
+		bs := block.NewMapBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		a := putAll(t, bs, rb0.Link(), objects)
+		b, diff, err := Put(ctx, bs, a, "dir0/file000.txt", testutil.RandomLink(t))
+		require.NoError(t, err)
+		return bs, a, b, diff
+	}
+
+	t.Run("without retain", func(t *testing.T) {
+		bs, a, _, diff := setup(t)
+		err := block.Apply(ctx, bs, diff)
+		require.NoError(t, err)
+
+		_, err = Get(ctx, bs, a, "dir0/file000.txt")
+		require.Error(t, err)
+	})
+
+	t.Run("with retain", func(t *testing.T) {
+		bs, a, b, diff := setup(t)
+		retain, err := Retain(ctx, bs, a)
+		require.NoError(t, err)
+		err = block.Apply(ctx, bs, diff, block.WithRetain(retain))
+		require.NoError(t, err)
+
+		for _, root := range []ipld.Link{a, b} {
+			problems, err := Verify(ctx, bs, root)
+			require.NoError(t, err)
+			require.Empty(t, problems)
+		}
+	})
+
+	t.Run("root not found", func(t *testing.T) {
+		_, err := Retain(ctx, block.NewMapBlockstore(), testutil.RandomLink(t))
+		require.Error(t, err)
+	})
+}
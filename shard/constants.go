@@ -3,6 +3,13 @@ package shard
 // KeyCharsASCII refers to printable ASCII characters in the code range 32-126.
 const KeyCharsASCII = "ascii"
 
+// KeyCharsUTF8 refers to any valid UTF-8 encoded characters.
+const KeyCharsUTF8 = "utf8"
+
+// KeyCharsBinary refers to arbitrary bytes. Keys are byte strings, which are
+// encoded as CBOR bytes in shards and ordered by byte value.
+const KeyCharsBinary = "binary"
+
 // MaxKeySize is a default maximum key size in bytes. It is the same as MAX_PATH
 // - the maximum filename+path size on most windows/unix systems, so should be
 // sufficient for most purposes.
@@ -7,40 +7,80 @@ import (
 	"github.com/storacha/go-pail/block"
 )
 
+// Kinds of decoded views of shards stored in a [block.ViewCache].
+const (
+	shardView     = "shard"
+	rootShardView = "shard/root"
+)
+
 type Fetcher struct {
 	blocks block.Fetcher
+	opts   []UnmarshalOption
+	cache  block.ViewCache
 }
 
 func (f *Fetcher) Get(ctx context.Context, link ipld.Link) (BlockView, error) {
+	// a cached view may not have been decoded with the options of this fetcher
+	cached := f.cache != nil && len(f.opts) == 0
+	if cached {
+		if b, ok := f.cache.GetView(link, shardView); ok {
+			if v, ok := b.(BlockView); ok {
+				return v, nil
+			}
+		}
+	}
+
 	b, err := f.blocks.Get(ctx, link)
 	if err != nil {
 		return nil, err
 	}
-
-	s, err := Unmarshal(b.Bytes())
+	s, err := Unmarshal(b.Bytes(), f.opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	return block.NewBlockView(link, b.Bytes(), s), nil
+	v := block.NewBlockView(link, b.Bytes(), s)
+	if cached {
+		f.cache.AddView(shardView, v)
+	}
+	return v, nil
 }
 
 func (f *Fetcher) GetRoot(ctx context.Context, link ipld.Link) (RootBlockView, error) {
+	cached := f.cache != nil && len(f.opts) == 0
+	if cached {
+		if b, ok := f.cache.GetView(link, rootShardView); ok {
+			if v, ok := b.(RootBlockView); ok {
+				return v, nil
+			}
+		}
+	}
+
 	b, err := f.blocks.Get(ctx, link)
 	if err != nil {
 		return nil, err
 	}
-
-	rs, err := UnmarshalRoot(b.Bytes())
+	rs, err := UnmarshalRoot(b.Bytes(), f.opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	return block.NewBlockView(link, b.Bytes(), rs), nil
+	v := block.NewBlockView(link, b.Bytes(), rs)
+	if cached {
+		f.cache.AddView(rootShardView, v)
+	}
+	return v, nil
 }
 
-func NewFetcher(blocks block.Fetcher) *Fetcher {
-	return &Fetcher{blocks}
+// NewFetcher creates a fetcher that decodes shards from the passed blocks.
+// Options are used when decoding, for example [WithStrict] to reject shards
+// that are not canonical. If blocks has a [block.ViewCache] (see
+// [block.ViewCacheOf]), for example a [block.CachingFetcher], decoded shards
+// are cached so that they are not decoded again. Fetchers with options do not
+// use the cache.
+func NewFetcher(blocks block.Fetcher, opts ...UnmarshalOption) *Fetcher {
+	cache, _ := block.ViewCacheOf(blocks)
+	return &Fetcher{blocks, opts, cache}
 }
 
 func AsBlock[S Shard](b block.BlockView[S]) BlockView {
@@ -0,0 +1,137 @@
+package shard
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/multiformats/go-multihash"
+	"github.com/storacha/go-pail/ipld/multicodec"
+)
+
+// inlineKey is the key of the single entry map that marks a value stored
+// inline in a shard entry, i.e. {"inline": data}.
+const inlineKey = "inline"
+
+// inlineLink is a link that contains the data of a value stored inline. It is
+// a distinct type so that a link to a block that happens to have an identity
+// multihash is never mistaken for an inline value.
+type inlineLink struct {
+	cidlink.Link
+}
+
+// InlineLink creates a link that contains the passed data. Values that are
+// inline links are stored inline in shard entries instead of as a link to a
+// separate block. They may only be put in pails that declare a maximum inline
+// size (see [WithMaxInlineSize]).
+//
+// The link is a DAG-CBOR CID with an identity multihash of the encoded data.
+// Other links with an identity multihash are not inline links.
+func InlineLink(n datamodel.Node) (ipld.Link, error) {
+	buf := bytes.NewBuffer([]byte{})
+	err := dagcbor.Encode(n, buf)
+	if err != nil {
+		return nil, fmt.Errorf("CBOR encoding: %w", err)
+	}
+	digest, err := multihash.Sum(buf.Bytes(), multihash.IDENTITY, -1)
+	if err != nil {
+		return nil, fmt.Errorf("identity hashing: %w", err)
+	}
+	return inlineLink{cidlink.Link{Cid: cid.NewCidV1(uint64(multicodec.DagCbor), digest)}}, nil
+}
+
+// IsInline returns true if the link is an inline link created by [InlineLink]
+// or decoded from a value stored inline in a shard.
+func IsInline(l ipld.Link) bool {
+	_, ok := l.(inlineLink)
+	return ok
+}
+
+// InlineSize returns the size in bytes of the encoded data contained in an
+// inline link.
+func InlineSize(l ipld.Link) (int, error) {
+	data, err := inlineData(l)
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// InlineNode decodes the data contained in an inline link.
+func InlineNode(l ipld.Link) (datamodel.Node, error) {
+	data, err := inlineData(l)
+	if err != nil {
+		return nil, err
+	}
+	nb := basicnode.Prototype.Any.NewBuilder()
+	err = dagcbor.Decode(nb, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("CBOR decoding: %w", err)
+	}
+	return nb.Build(), nil
+}
+
+func inlineData(l ipld.Link) ([]byte, error) {
+	il, ok := l.(inlineLink)
+	if !ok {
+		return nil, fmt.Errorf("not an inline link: %s", l.String())
+	}
+	dmh, err := multihash.Decode(il.Cid.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("decoding multihash: %w", err)
+	}
+	return dmh.Digest, nil
+}
+
+// AssignValue assigns a value of a pail entry: a link to user data, or the
+// data of an inline link in the form {"inline": data}.
+func AssignValue(na datamodel.NodeAssembler, l ipld.Link) error {
+	if !IsInline(l) {
+		return na.AssignLink(l)
+	}
+	n, err := InlineNode(l)
+	if err != nil {
+		return fmt.Errorf("decoding inline value: %w", err)
+	}
+	ma, err := na.BeginMap(1)
+	if err != nil {
+		return err
+	}
+	err = ma.AssembleKey().AssignString(inlineKey)
+	if err != nil {
+		return err
+	}
+	err = ma.AssembleValue().AssignNode(n)
+	if err != nil {
+		return err
+	}
+	return ma.Finish()
+}
+
+// DecodeValue decodes a value assigned by [AssignValue]. Data stored inline is
+// returned as an inline link.
+func DecodeValue(n datamodel.Node) (ipld.Link, error) {
+	switch n.Kind() {
+	case datamodel.Kind_Link:
+		return n.AsLink()
+	case datamodel.Kind_Map:
+		if n.Length() != 1 {
+			break
+		}
+		dn, err := n.LookupByString(inlineKey)
+		if err != nil {
+			break
+		}
+		l, err := InlineLink(dn)
+		if err != nil {
+			return nil, fmt.Errorf("encoding inline value: %w", err)
+		}
+		return l, nil
+	}
+	return nil, fmt.Errorf("value is not a link or inline data: %s", n.Kind())
+}
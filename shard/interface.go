@@ -16,3 +16,25 @@ type Diff struct {
 	Additions []BlockView
 	Removals  []BlockView
 }
+
+// AddedBlocks returns the added shard blocks, so that a diff can be passed to
+// [block.Apply].
+func (d Diff) AddedBlocks() []block.Block {
+	return toBlocks(d.Additions)
+}
+
+// RemovedBlocks returns the removed shard blocks, so that a diff can be passed
+// to [block.Apply].
+func (d Diff) RemovedBlocks() []block.Block {
+	return toBlocks(d.Removals)
+}
+
+func toBlocks(views []BlockView) []block.Block {
+	blocks := make([]block.Block, 0, len(views))
+	for _, v := range views {
+		blocks = append(blocks, v)
+	}
+	return blocks
+}
+
+var _ block.Changes = Diff{}
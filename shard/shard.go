@@ -45,7 +45,8 @@ type Value interface {
 	// user data.
 	Shard() ipld.Link
 	// Value is a link to user data, which may be nil if this value is a link to
-	// a shard.
+	// a shard. If it is an inline link (see [InlineLink]) the data is encoded
+	// inline in the shard.
 	Value() ipld.Link
 }
 
@@ -75,6 +76,13 @@ type Shard interface {
 type shard struct {
 	prefix  string
 	entries []Entry
+	// binary indicates the prefix and entry keys are encoded as CBOR bytes.
+	binary bool
+	// version is the version of the pail the shard belongs to.
+	version int64
+	// fanout indicates the entries of the shard link to its parts (see
+	// [WithFanout]).
+	fanout bool
 }
 
 func (s shard) Prefix() string {
@@ -85,8 +93,84 @@ func (s shard) Entries() []Entry {
 	return s.entries
 }
 
-func New(prefix string, entries []Entry) Shard {
-	return shard{prefix, entries}
+// Option configures a [Shard] created by [New] or [NewBinary].
+type Option func(*shard)
+
+// WithShardVersion sets the version of the pail the shard belongs to, which
+// must be the same as the version of the root shard. The default is [Version].
+func WithShardVersion(version int64) Option {
+	return func(s *shard) {
+		s.version = version
+	}
+}
+
+// WithFanout makes the shard a fanout shard, whose entries link to parts of a
+// shard that was split because it exceeded the maximum shard size of the pail
+// (see [WithMaxShardSize]). Parts have the same prefix as the fanout shard, and
+// each holds a run of the entries of the shard it was split from, with entries
+// that start with the same character kept in the same part. The key of a
+// fanout entry is the first character of the first entry of the part it links
+// to, except for the first fanout entry, which has an empty key. A key belongs
+// to the part linked by the last fanout entry with a key that is less than or
+// equal to it. Parts may themselves be fanout shards.
+func WithFanout() Option {
+	return func(s *shard) {
+		s.fanout = true
+	}
+}
+
+func New(prefix string, entries []Entry, opts ...Option) Shard {
+	s := shard{prefix: prefix, entries: entries, version: Version}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// NewBinary creates a shard whose prefix and entry keys are arbitrary byte
+// strings, which are encoded as CBOR bytes. Binary shards belong to pails with a
+// root shard that uses the [KeyCharsBinary] key character set.
+func NewBinary(prefix string, entries []Entry, opts ...Option) Shard {
+	s := shard{prefix: prefix, entries: entries, binary: true, version: Version}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// IsBinary returns true if the prefix and entry keys of the shard are encoded
+// as CBOR bytes.
+func IsBinary(s Shard) bool {
+	switch s := s.(type) {
+	case shard:
+		return s.binary
+	case RootShard:
+		return s.KeyChars() == KeyCharsBinary
+	}
+	return false
+}
+
+// IsFanout returns true if the entries of the shard link to its parts (see
+// [WithFanout]).
+func IsFanout(s Shard) bool {
+	switch s := s.(type) {
+	case shard:
+		return s.fanout
+	case rootshard:
+		return s.fanout
+	}
+	return false
+}
+
+// VersionOf returns the version of the pail the shard belongs to.
+func VersionOf(s Shard) int64 {
+	switch s := s.(type) {
+	case shard:
+		return s.version
+	case RootShard:
+		return s.Version()
+	}
+	return Version
 }
 
 type RootShard interface {
@@ -99,13 +183,22 @@ type RootShard interface {
 	KeyChars() string
 	// MaxKeySize is the maximum key size in bytes - default 4096 bytes.
 	MaxKeySize() int64
+	// MaxInlineSize is the maximum size in bytes of the encoded data of values
+	// that are stored inline in shard entries - default 0, meaning values may
+	// not be stored inline.
+	MaxInlineSize() int64
+	// MaxShardSize is the size in bytes above which encoded shards are split
+	// into parts (see [WithMaxShardSize]) - default 0, meaning shards are not
+	// split by size.
+	MaxShardSize() int64
 }
 
 type rootshard struct {
 	shard
-	version    int64
-	keyChars   string
-	maxKeySize int64
+	keyChars      string
+	maxKeySize    int64
+	maxInlineSize int64
+	maxShardSize  int64
 }
 
 func (r rootshard) KeyChars() string {
@@ -116,21 +209,95 @@ func (r rootshard) MaxKeySize() int64 {
 	return r.maxKeySize
 }
 
+func (r rootshard) MaxInlineSize() int64 {
+	return r.maxInlineSize
+}
+
+func (r rootshard) MaxShardSize() int64 {
+	return r.maxShardSize
+}
+
 func (r rootshard) Version() int64 {
 	return r.version
 }
 
-func NewRoot(entries []Entry) RootShard {
-	return rootshard{shard: shard{"", entries}, version: Version, keyChars: KeyCharsASCII, maxKeySize: MaxKeySize}
+// RootOption configures a [RootShard] created by [NewRoot].
+type RootOption func(*rootshard)
+
+// WithVersion sets the version of the pail, which determines how shards are
+// encoded. See [SupportedVersion]. The default is [Version].
+func WithVersion(version int64) RootOption {
+	return func(r *rootshard) {
+		r.version = version
+	}
+}
+
+// WithKeyChars sets the character set allowed in keys. e.g. [KeyCharsASCII] or
+// [KeyCharsUTF8]. The default is [KeyCharsASCII].
+func WithKeyChars(keyChars string) RootOption {
+	return func(r *rootshard) {
+		r.keyChars = keyChars
+	}
+}
+
+// WithMaxKeySize sets the maximum key size in bytes. The default is
+// [MaxKeySize].
+func WithMaxKeySize(size int64) RootOption {
+	return func(r *rootshard) {
+		r.maxKeySize = size
+	}
+}
+
+// WithMaxInlineSize sets the maximum size in bytes of the encoded data of
+// values that are stored inline in shard entries. The default is 0, meaning
+// values may not be stored inline.
+func WithMaxInlineSize(size int64) RootOption {
+	return func(r *rootshard) {
+		r.maxInlineSize = size
+	}
+}
+
+// WithMaxShardSize sets the maximum size in bytes of an encoded shard. Shards
+// that exceed the maximum size are split into parts linked from a fanout shard
+// (see [WithFanout]), which are not merged again when entries are deleted, so
+// the shape of the pail depends on the order of operations as well as its
+// entries. Entries that start with the same character are never split across
+// parts, so a shard may only exceed the maximum size if a single entry, or the
+// entries that share a first character, do. The default is 0, meaning shards
+// are not split by size.
+func WithMaxShardSize(size int64) RootOption {
+	return func(r *rootshard) {
+		r.maxShardSize = size
+	}
+}
+
+// WithRootFanout makes the root shard a fanout shard, whose entries link to
+// its parts (see [WithFanout]). Only pails with a maximum shard size have
+// fanout shards.
+func WithRootFanout() RootOption {
+	return func(r *rootshard) {
+		r.fanout = true
+	}
 }
 
-const Version = 2
+func NewRoot(entries []Entry, opts ...RootOption) RootShard {
+	rs := rootshard{shard: shard{prefix: "", entries: entries, version: Version}, keyChars: KeyCharsASCII, maxKeySize: MaxKeySize}
+	for _, opt := range opts {
+		opt(&rs)
+	}
+	return rs
+}
 
 // Marshal serializes a [Shard] or a [RootShard] to CBOR encoded bytes.
 func Marshal(s Shard) ([]byte, error) {
 	np := basicnode.Prototype.Any
 	nb := np.NewBuilder()
 
+	f, ok := formats[VersionOf(s)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported version: %d", VersionOf(s))
+	}
+
 	ma, err := nb.BeginMap(5)
 	if err != nil {
 		return nil, fmt.Errorf("beginning map: %w", err)
@@ -163,13 +330,59 @@ func Marshal(s Shard) ([]byte, error) {
 		if err != nil {
 			return nil, fmt.Errorf("assembling maximum key size value: %w", err)
 		}
+
+		// only encoded when set, so that the encoding of root shards that do not
+		// allow inline values or split shards by size is unchanged.
+		if rs.MaxInlineSize() != 0 {
+			err = ma.AssembleKey().AssignString("maxInlineSize")
+			if err != nil {
+				return nil, fmt.Errorf("assembling maximum inline size key: %w", err)
+			}
+			err = ma.AssembleValue().AssignInt(rs.MaxInlineSize())
+			if err != nil {
+				return nil, fmt.Errorf("assembling maximum inline size value: %w", err)
+			}
+		}
+		if rs.MaxShardSize() != 0 {
+			err = ma.AssembleKey().AssignString("maxShardSize")
+			if err != nil {
+				return nil, fmt.Errorf("assembling maximum shard size key: %w", err)
+			}
+			err = ma.AssembleValue().AssignInt(rs.MaxShardSize())
+			if err != nil {
+				return nil, fmt.Errorf("assembling maximum shard size value: %w", err)
+			}
+		}
+	} else if f.shardVersion {
+		err = ma.AssembleKey().AssignString("version")
+		if err != nil {
+			return nil, fmt.Errorf("assembling version key: %w", err)
+		}
+		err = ma.AssembleValue().AssignInt(VersionOf(s))
+		if err != nil {
+			return nil, fmt.Errorf("assembling version value: %w", err)
+		}
+	}
+
+	// only encoded for fanout shards, so that the encoding of other shards is
+	// unchanged.
+	if IsFanout(s) {
+		err = ma.AssembleKey().AssignString("fanout")
+		if err != nil {
+			return nil, fmt.Errorf("assembling fanout key: %w", err)
+		}
+		err = ma.AssembleValue().AssignBool(true)
+		if err != nil {
+			return nil, fmt.Errorf("assembling fanout value: %w", err)
+		}
 	}
 
 	err = ma.AssembleKey().AssignString("prefix")
 	if err != nil {
 		return nil, fmt.Errorf("assembling prefix key: %w", err)
 	}
-	err = ma.AssembleValue().AssignString(s.Prefix())
+	binary := IsBinary(s)
+	err = assignKey(ma.AssembleValue(), s.Prefix(), binary)
 	if err != nil {
 		return nil, fmt.Errorf("assembling prefix value: %w", err)
 	}
@@ -186,7 +399,7 @@ func Marshal(s Shard) ([]byte, error) {
 	}
 
 	for _, ent := range s.Entries() {
-		n, err := unwrapEntry(ent)
+		n, err := unwrapEntry(ent, binary)
 		if err != nil {
 			return nil, fmt.Errorf("encoding entry node: %w", err)
 		}
@@ -220,14 +433,14 @@ func Marshal(s Shard) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func unwrapEntry(e Entry) (datamodel.Node, error) {
+func unwrapEntry(e Entry, binary bool) (datamodel.Node, error) {
 	np := basicnode.Prototype.Any
 	nb := np.NewBuilder()
 	la, err := nb.BeginList(2)
 	if err != nil {
 		return nil, fmt.Errorf("beginning entry list: %w", err)
 	}
-	err = la.AssembleValue().AssignString(e.Key())
+	err = assignKey(la.AssembleValue(), e.Key(), binary)
 	if err != nil {
 		return nil, fmt.Errorf("assembling entry key: %w", err)
 	}
@@ -251,9 +464,9 @@ func unwrapValue(v Value) (datamodel.Node, error) {
 	nb := np.NewBuilder()
 
 	if v.Shard() == nil {
-		err := nb.AssignLink(v.Value())
+		err := AssignValue(nb, v.Value())
 		if err != nil {
-			return nil, fmt.Errorf("assembling value link: %w", err)
+			return nil, fmt.Errorf("assembling value: %w", err)
 		}
 		return nb.Build(), nil
 	}
@@ -267,9 +480,9 @@ func unwrapValue(v Value) (datamodel.Node, error) {
 		return nil, fmt.Errorf("assembling shard link: %w", err)
 	}
 	if v.Value() != nil {
-		err = la.AssembleValue().AssignLink(v.Value())
+		err = AssignValue(la.AssembleValue(), v.Value())
 		if err != nil {
-			return nil, fmt.Errorf("assembling value link: %w", err)
+			return nil, fmt.Errorf("assembling value: %w", err)
 		}
 	}
 	err = la.Finish()
@@ -294,9 +507,11 @@ func MarshalBlock[S Shard](s S) (block.BlockView[S], error) {
 	return block.NewBlockView(link, bytes, s), nil
 }
 
-// Unmarshal deserializes CBOR encoded bytes to a [Shard].
-func Unmarshal(b []byte) (Shard, error) {
+// Unmarshal deserializes CBOR encoded bytes to a [Shard]. Pass [WithStrict] to
+// reject shards that are not canonical.
+func Unmarshal(b []byte, opts ...UnmarshalOption) (Shard, error) {
 	var s shard
+	o := newUnmarshalOptions(opts)
 
 	np := basicnode.Prototype.Map
 	nb := np.NewBuilder()
@@ -306,15 +521,59 @@ func Unmarshal(b []byte) (Shard, error) {
 	}
 	n := nb.Build()
 
+	// the version is encoded in root shards, and in every shard of versions
+	// that require it, otherwise the shard is assumed to be the default version.
+	s.version = Version
+	vn, err := n.LookupByString("version")
+	if err == nil {
+		version, err := vn.AsInt()
+		if err != nil {
+			return nil, fmt.Errorf("decoding version as int: %w", err)
+		}
+		if !SupportedVersion(version) {
+			return nil, fmt.Errorf("unsupported version: %d", version)
+		}
+		s.version = version
+	} else if !errors.As(err, &datamodel.ErrNotExists{}) {
+		return nil, fmt.Errorf("looking up version: %w", err)
+	}
+
+	s.fanout, err = decodeFanout(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.strict {
+		fields := []string{"prefix", "entries"}
+		if formats[s.version].shardVersion {
+			fields = append(fields, "version")
+		}
+		if s.fanout {
+			fields = append(fields, "fanout")
+		}
+		err = checkFields(n, fields...)
+		if err != nil {
+			return nil, err
+		}
+		err = checkCanonical(n, b)
+		if err != nil {
+			return nil, err
+		}
+		if o.version != 0 && s.version != o.version {
+			return nil, fmt.Errorf("%w: shard is version %d, pail is version %d", ErrVersionMismatch, s.version, o.version)
+		}
+	}
+
 	pfxn, err := n.LookupByString("prefix")
 	if err != nil {
 		return nil, fmt.Errorf("looking up prefix: %w", err)
 	}
-	prefix, err := pfxn.AsString()
+	prefix, err := decodeKey(pfxn)
 	if err != nil {
-		return nil, fmt.Errorf("decoding prefix as string: %w", err)
+		return nil, fmt.Errorf("decoding prefix: %w", err)
 	}
 	s.prefix = prefix
+	s.binary = pfxn.Kind() == datamodel.Kind_Bytes
 
 	en, err := n.LookupByString("entries")
 	if err != nil {
@@ -332,6 +591,12 @@ func Unmarshal(b []byte) (Shard, error) {
 		if err != nil {
 			return nil, fmt.Errorf("iterating entries: %w", err)
 		}
+		if o.strict {
+			err = checkEntryNode(n)
+			if err != nil {
+				return nil, err
+			}
+		}
 		ent, err := wrapEntry(n)
 		if err != nil {
 			return nil, fmt.Errorf("decoding entry node: %w", err)
@@ -339,12 +604,40 @@ func Unmarshal(b []byte) (Shard, error) {
 		s.entries = append(s.entries, ent)
 	}
 
+	if o.strict {
+		// parts of the root shard may hold the entry with an empty key, and the
+		// first entry of a fanout shard has one
+		err = checkEntries(s.prefix, s.entries, o.maxKeySize, s.prefix == "" || s.fanout)
+		if err != nil {
+			return nil, err
+		}
+		if s.fanout {
+			if o.maxShardSize == 0 {
+				return nil, fmt.Errorf("%w: pail does not have a maximum shard size", ErrInvalidFanout)
+			}
+			err = checkFanout(s.entries)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if o.maxInlineSize >= 0 {
+			err = checkInline(s.prefix, s.entries, o.maxInlineSize)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return s, nil
 }
 
-// UnmarshalRoot deserializes CBOR encoded bytes to a [RootShard].
-func UnmarshalRoot(b []byte) (RootShard, error) {
+// UnmarshalRoot deserializes CBOR encoded bytes to a [RootShard]. Pass
+// [WithStrict] to reject root shards that are not canonical. Root shards with
+// values stored inline are rejected unless they declare a maximum inline size
+// that the values do not exceed.
+func UnmarshalRoot(b []byte, opts ...UnmarshalOption) (RootShard, error) {
 	var rs rootshard
+	o := newUnmarshalOptions(opts)
 
 	np := basicnode.Prototype.Map
 	nb := np.NewBuilder()
@@ -362,7 +655,7 @@ func UnmarshalRoot(b []byte) (RootShard, error) {
 	if err != nil {
 		return nil, fmt.Errorf("decoding version as int: %w", err)
 	}
-	if version != Version {
+	if !SupportedVersion(version) {
 		return nil, fmt.Errorf("unsupported version: %d", version)
 	}
 	rs.version = version
@@ -385,18 +678,69 @@ func UnmarshalRoot(b []byte) (RootShard, error) {
 	if err != nil {
 		return nil, fmt.Errorf("decoding maximum key size as int: %w", err)
 	}
+	if maxKeySize < 1 {
+		return nil, fmt.Errorf("invalid maximum key size: %d", maxKeySize)
+	}
 	rs.maxKeySize = maxKeySize
 
+	misn, err := n.LookupByString("maxInlineSize")
+	if err == nil {
+		maxInlineSize, err := misn.AsInt()
+		if err != nil {
+			return nil, fmt.Errorf("decoding maximum inline size as int: %w", err)
+		}
+		if maxInlineSize < 1 {
+			return nil, fmt.Errorf("invalid maximum inline size: %d", maxInlineSize)
+		}
+		rs.maxInlineSize = maxInlineSize
+	} else if !errors.As(err, &datamodel.ErrNotExists{}) {
+		return nil, fmt.Errorf("looking up maximum inline size: %w", err)
+	}
+
+	mssn, err := n.LookupByString("maxShardSize")
+	if err == nil {
+		maxShardSize, err := mssn.AsInt()
+		if err != nil {
+			return nil, fmt.Errorf("decoding maximum shard size as int: %w", err)
+		}
+		if maxShardSize < 1 {
+			return nil, fmt.Errorf("invalid maximum shard size: %d", maxShardSize)
+		}
+		rs.maxShardSize = maxShardSize
+	} else if !errors.As(err, &datamodel.ErrNotExists{}) {
+		return nil, fmt.Errorf("looking up maximum shard size: %w", err)
+	}
+
 	pfxn, err := n.LookupByString("prefix")
 	if err != nil {
 		return nil, fmt.Errorf("looking up prefix: %w", err)
 	}
-	prefix, err := pfxn.AsString()
+	prefix, err := decodeKey(pfxn)
 	if err != nil {
-		return nil, fmt.Errorf("decoding prefix as string: %w", err)
+		return nil, fmt.Errorf("decoding prefix: %w", err)
 	}
 	rs.prefix = prefix
 
+	rs.fanout, err = decodeFanout(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.strict {
+		fields := []string{"version", "keyChars", "maxKeySize", "maxInlineSize", "maxShardSize", "prefix", "entries"}
+		if rs.fanout {
+			fields = append(fields, "fanout")
+		}
+		err = checkFields(n, fields...)
+		if err != nil {
+			return nil, err
+		}
+		err = checkCanonical(n, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	en, err := n.LookupByString("entries")
 	if err != nil {
 		return nil, fmt.Errorf("looking up entries: %w", err)
@@ -413,6 +757,12 @@ func UnmarshalRoot(b []byte) (RootShard, error) {
 		if err != nil {
 			return nil, fmt.Errorf("iterating entries: %w", err)
 		}
+		if o.strict {
+			err = checkEntryNode(n)
+			if err != nil {
+				return nil, err
+			}
+		}
 		ent, err := wrapEntry(n)
 		if err != nil {
 			return nil, fmt.Errorf("decoding entry node: %w", err)
@@ -420,15 +770,54 @@ func UnmarshalRoot(b []byte) (RootShard, error) {
 		rs.entries = append(rs.entries, ent)
 	}
 
+	if o.strict {
+		err = checkEntries(rs.prefix, rs.entries, rs.maxKeySize, true)
+		if err != nil {
+			return nil, err
+		}
+		if rs.fanout {
+			if rs.maxShardSize == 0 {
+				return nil, fmt.Errorf("%w: pail does not have a maximum shard size", ErrInvalidFanout)
+			}
+			err = checkFanout(rs.entries)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	// inline data is only interpreted as such in pails that allow it
+	err = checkInline(rs.prefix, rs.entries, rs.maxInlineSize)
+	if err != nil {
+		return nil, err
+	}
+
 	return rs, nil
 }
 
+// decodeFanout decodes the optional fanout field of a shard (see
+// [WithFanout]), which is only encoded for fanout shards. A fanout field that
+// is false is rejected as an unknown field in strict mode.
+func decodeFanout(n datamodel.Node) (bool, error) {
+	fn, err := n.LookupByString("fanout")
+	if err != nil {
+		if errors.As(err, &datamodel.ErrNotExists{}) {
+			return false, nil
+		}
+		return false, fmt.Errorf("looking up fanout: %w", err)
+	}
+	fanout, err := fn.AsBool()
+	if err != nil {
+		return false, fmt.Errorf("decoding fanout as bool: %w", err)
+	}
+	return fanout, nil
+}
+
 func wrapEntry(n datamodel.Node) (Entry, error) {
 	kn, err := n.LookupByIndex(0)
 	if err != nil {
 		return nil, fmt.Errorf("looking up key: %w", err)
 	}
-	key, err := kn.AsString()
+	key, err := decodeKey(kn)
 	if err != nil {
 		return nil, fmt.Errorf("decoding key: %w", err)
 	}
@@ -443,9 +832,37 @@ func wrapEntry(n datamodel.Node) (Entry, error) {
 	return entry{key, value}, nil
 }
 
+// assignKey assigns a key or prefix as a CBOR string, or as CBOR bytes for
+// shards with binary keys.
+func assignKey(na datamodel.NodeAssembler, key string, binary bool) error {
+	if binary {
+		return na.AssignBytes([]byte(key))
+	}
+	return na.AssignString(key)
+}
+
+// decodeKey decodes a key or prefix encoded as a CBOR string or CBOR bytes.
+func decodeKey(n datamodel.Node) (string, error) {
+	if n.Kind() == datamodel.Kind_Bytes {
+		b, err := n.AsBytes()
+		if err != nil {
+			return "", fmt.Errorf("decoding as bytes: %w", err)
+		}
+		return string(b), nil
+	}
+	s, err := n.AsString()
+	if err != nil {
+		return "", fmt.Errorf("decoding as string: %w", err)
+	}
+	return s, nil
+}
+
 func wrapValue(n datamodel.Node) (Value, error) {
-	l, err := n.AsLink()
-	if err == nil {
+	if n.Kind() != datamodel.Kind_List {
+		l, err := DecodeValue(n)
+		if err != nil {
+			return nil, fmt.Errorf("decoding value: %w", err)
+		}
 		return value{nil, l}, nil
 	}
 	sn, err := n.LookupByIndex(0)
@@ -461,9 +878,9 @@ func wrapValue(n datamodel.Node) (Value, error) {
 	if err != nil {
 		return value{shard, nil}, nil
 	}
-	val, err := vn.AsLink()
+	val, err := DecodeValue(vn)
 	if err != nil {
-		return nil, fmt.Errorf("decoding value link: %w", err)
+		return nil, fmt.Errorf("decoding value: %w", err)
 	}
 	return value{shard, val}, nil
 }
@@ -1,9 +1,19 @@
 package shard_test
 
 import (
+	"bytes"
 	"testing"
 
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/multiformats/go-multihash"
 	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/ipld/multicodec"
 	"github.com/storacha/go-pail/shard"
 	"github.com/stretchr/testify/require"
 )
@@ -54,6 +64,39 @@ func TestMarshalUnmarshal(t *testing.T) {
 				},
 			),
 		},
+		{
+			Name: "inline value entry",
+			Data: shard.New(
+				"",
+				[]shard.Entry{
+					shard.NewEntry("test", shard.NewValue(inlineLink(t, basicnode.NewString("inline")), nil)),
+				},
+			),
+		},
+		{
+			Name: "shard and inline value entry",
+			Data: shard.New(
+				"",
+				[]shard.Entry{
+					shard.NewEntry(
+						"test",
+						shard.NewValue(
+							inlineLink(t, basicnode.NewInt(138)),
+							testutil.RandomLink(t),
+						),
+					),
+				},
+			),
+		},
+		{
+			Name: "binary keys",
+			Data: shard.NewBinary(
+				"\xff",
+				[]shard.Entry{
+					shard.NewEntry("\x00\xc3", shard.NewValue(testutil.RandomLink(t), nil)),
+				},
+			),
+		},
 	}
 
 	for _, v := range vectors {
@@ -81,3 +124,260 @@ func TestMarshalUnmarshalRoot(t *testing.T) {
 
 	require.Equal(t, r, s)
 }
+
+func TestMarshalUnmarshalRootKeyChars(t *testing.T) {
+	r := shard.NewRoot([]shard.Entry{
+		shard.NewEntry("日本", shard.NewValue(testutil.RandomLink(t), nil)),
+	}, shard.WithKeyChars(shard.KeyCharsUTF8))
+	b, err := shard.Marshal(r)
+	require.NoError(t, err)
+
+	s, err := shard.UnmarshalRoot(b)
+	require.NoError(t, err)
+
+	require.Equal(t, r, s)
+	require.Equal(t, shard.KeyCharsUTF8, s.KeyChars())
+}
+
+func TestMarshalUnmarshalRootMaxKeySize(t *testing.T) {
+	r := shard.NewRoot(nil, shard.WithMaxKeySize(1024))
+	b, err := shard.Marshal(r)
+	require.NoError(t, err)
+
+	s, err := shard.UnmarshalRoot(b)
+	require.NoError(t, err)
+	require.Equal(t, int64(1024), s.MaxKeySize())
+
+	b, err = shard.Marshal(shard.NewRoot(nil, shard.WithMaxKeySize(0)))
+	require.NoError(t, err)
+
+	_, err = shard.UnmarshalRoot(b)
+	require.ErrorContains(t, err, "invalid maximum key size")
+}
+
+func TestMarshalUnmarshalRootBinary(t *testing.T) {
+	r := shard.NewRoot([]shard.Entry{
+		shard.NewEntry("\x00\xc3", shard.NewValue(testutil.RandomLink(t), nil)),
+	}, shard.WithKeyChars(shard.KeyCharsBinary))
+	b, err := shard.Marshal(r)
+	require.NoError(t, err)
+
+	s, err := shard.UnmarshalRoot(b)
+	require.NoError(t, err)
+
+	require.Equal(t, r, s)
+	require.True(t, shard.IsBinary(s))
+}
+
+func TestMarshalUnmarshalVersions(t *testing.T) {
+	entries := []shard.Entry{
+		shard.NewEntry("test", shard.NewValue(testutil.RandomLink(t), nil)),
+	}
+
+	t.Run("version 2 shards do not encode the version", func(t *testing.T) {
+		b2, err := shard.Marshal(shard.New("pfx", entries))
+		require.NoError(t, err)
+		b3, err := shard.Marshal(shard.New("pfx", entries, shard.WithShardVersion(shard.Version3)))
+		require.NoError(t, err)
+		require.Greater(t, len(b3), len(b2))
+
+		s, err := shard.Unmarshal(b2)
+		require.NoError(t, err)
+		require.Equal(t, int64(shard.Version2), shard.VersionOf(s))
+
+		s, err = shard.Unmarshal(b3)
+		require.NoError(t, err)
+		require.Equal(t, int64(shard.Version3), shard.VersionOf(s))
+		require.Equal(t, shard.New("pfx", entries, shard.WithShardVersion(shard.Version3)), s)
+	})
+
+	t.Run("root shard", func(t *testing.T) {
+		r := shard.NewRoot(entries, shard.WithVersion(shard.Version3))
+		b, err := shard.Marshal(r)
+		require.NoError(t, err)
+
+		s, err := shard.UnmarshalRoot(b)
+		require.NoError(t, err)
+		require.Equal(t, r, s)
+		require.Equal(t, int64(shard.Version3), s.Version())
+		require.Equal(t, int64(shard.Version3), shard.VersionOf(s))
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		_, err := shard.Marshal(shard.NewRoot(nil, shard.WithVersion(1)))
+		require.ErrorContains(t, err, "unsupported version: 1")
+
+		_, err = shard.Marshal(shard.New("pfx", entries, shard.WithShardVersion(4)))
+		require.ErrorContains(t, err, "unsupported version: 4")
+
+		b, err := qp.BuildMap(basicnode.Prototype.Any, 3, func(ma datamodel.MapAssembler) {
+			qp.MapEntry(ma, "version", qp.Int(4))
+			qp.MapEntry(ma, "prefix", qp.String("pfx"))
+			qp.MapEntry(ma, "entries", qp.List(0, func(la datamodel.ListAssembler) {}))
+		})
+		require.NoError(t, err)
+		buf := bytes.NewBuffer([]byte{})
+		err = dagcbor.Encode(b, buf)
+		require.NoError(t, err)
+		_, err = shard.Unmarshal(buf.Bytes())
+		require.ErrorContains(t, err, "unsupported version: 4")
+	})
+
+	require.Equal(t, []int64{shard.Version2, shard.Version3}, shard.SupportedVersions())
+}
+
+func TestMarshalUnmarshalRootMaxInlineSize(t *testing.T) {
+	b, err := shard.Marshal(shard.NewRoot(nil))
+	require.NoError(t, err)
+
+	r := shard.NewRoot(nil, shard.WithMaxInlineSize(128))
+	ib, err := shard.Marshal(r)
+	require.NoError(t, err)
+	// the field is only encoded when set
+	require.Greater(t, len(ib), len(b))
+
+	s, err := shard.UnmarshalRoot(ib)
+	require.NoError(t, err)
+	require.Equal(t, r, s)
+	require.Equal(t, int64(128), s.MaxInlineSize())
+
+	s, err = shard.UnmarshalRoot(b)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), s.MaxInlineSize())
+}
+
+func TestMarshalUnmarshalRootMaxShardSize(t *testing.T) {
+	b, err := shard.Marshal(shard.NewRoot(nil))
+	require.NoError(t, err)
+
+	r := shard.NewRoot(nil, shard.WithMaxShardSize(512*1024))
+	sb, err := shard.Marshal(r)
+	require.NoError(t, err)
+	// the field is only encoded when set
+	require.Greater(t, len(sb), len(b))
+
+	s, err := shard.UnmarshalRoot(sb)
+	require.NoError(t, err)
+	require.Equal(t, r, s)
+	require.Equal(t, int64(512*1024), s.MaxShardSize())
+
+	s, err = shard.UnmarshalRoot(b)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), s.MaxShardSize())
+}
+
+func TestInlineLink(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		nodes := []datamodel.Node{
+			basicnode.NewBytes([]byte{1, 2, 3}),
+			basicnode.NewString("hello"),
+			basicnode.NewInt(-1),
+			basicnode.NewBool(true),
+		}
+		m, err := qp.BuildMap(basicnode.Prototype.Any, 2, func(ma datamodel.MapAssembler) {
+			qp.MapEntry(ma, "size", qp.Int(138))
+			qp.MapEntry(ma, "type", qp.String("text/plain"))
+		})
+		require.NoError(t, err)
+		nodes = append(nodes, m)
+
+		for _, n := range nodes {
+			l, err := shard.InlineLink(n)
+			require.NoError(t, err)
+			require.True(t, shard.IsInline(l))
+
+			d, err := shard.InlineNode(l)
+			require.NoError(t, err)
+			require.True(t, datamodel.DeepEqual(n, d))
+		}
+	})
+
+	t.Run("not inline", func(t *testing.T) {
+		l := testutil.RandomLink(t)
+		require.False(t, shard.IsInline(l))
+		_, err := shard.InlineNode(l)
+		require.ErrorContains(t, err, "not an inline link")
+	})
+
+	t.Run("identity link", func(t *testing.T) {
+		// a link to a list of strings, with an identity multihash
+		digest, err := multihash.Sum([]byte{0x82, 0x61, 0x61, 0x61, 0x62}, multihash.IDENTITY, -1)
+		require.NoError(t, err)
+		l := cidlink.Link{Cid: cid.NewCidV1(uint64(multicodec.DagCbor), digest)}
+		require.False(t, shard.IsInline(l))
+
+		s := shard.New("", []shard.Entry{shard.NewEntry("a", shard.NewValue(l, nil))})
+		b, err := shard.Marshal(s)
+		require.NoError(t, err)
+		d, err := shard.Unmarshal(b, shard.WithStrict(), shard.WithPailMaxInlineSize(0))
+		require.NoError(t, err)
+		require.Equal(t, l, d.Entries()[0].Value().Value())
+	})
+}
+
+func TestMarshalUnmarshalInline(t *testing.T) {
+	v := inlineLink(t, basicnode.NewString("hello"))
+	entries := []shard.Entry{
+		shard.NewEntry("a", shard.NewValue(v, nil)),
+		shard.NewEntry("b", shard.NewValue(v, testutil.RandomLink(t))),
+	}
+
+	t.Run("encoded as inline data", func(t *testing.T) {
+		b, err := shard.Marshal(shard.New("", entries))
+		require.NoError(t, err)
+
+		nb := basicnode.Prototype.Any.NewBuilder()
+		err = dagcbor.Decode(nb, bytes.NewReader(b))
+		require.NoError(t, err)
+		en, err := nb.Build().LookupByString("entries")
+		require.NoError(t, err)
+		e0, err := en.LookupByIndex(0)
+		require.NoError(t, err)
+		vn, err := e0.LookupByIndex(1)
+		require.NoError(t, err)
+		dn, err := vn.LookupByString("inline")
+		require.NoError(t, err)
+		str, err := dn.AsString()
+		require.NoError(t, err)
+		require.Equal(t, "hello", str)
+
+		s, err := shard.Unmarshal(b, shard.WithStrict())
+		require.NoError(t, err)
+		require.Equal(t, entries, s.Entries())
+	})
+
+	t.Run("root without max inline size", func(t *testing.T) {
+		b, err := shard.Marshal(shard.NewRoot(entries))
+		require.NoError(t, err)
+		_, err = shard.UnmarshalRoot(b)
+		require.ErrorIs(t, err, shard.ErrInlineNotAllowed)
+
+		b, err = shard.Marshal(shard.NewRoot(entries, shard.WithMaxInlineSize(4)))
+		require.NoError(t, err)
+		_, err = shard.UnmarshalRoot(b)
+		require.ErrorIs(t, err, shard.ErrInlineTooLarge)
+
+		b, err = shard.Marshal(shard.NewRoot(entries, shard.WithMaxInlineSize(64)))
+		require.NoError(t, err)
+		r, err := shard.UnmarshalRoot(b)
+		require.NoError(t, err)
+		require.Equal(t, entries, r.Entries())
+	})
+
+	t.Run("pail max inline size", func(t *testing.T) {
+		b, err := shard.Marshal(shard.New("", entries))
+		require.NoError(t, err)
+		_, err = shard.Unmarshal(b, shard.WithStrict(), shard.WithPailMaxInlineSize(0))
+		require.ErrorIs(t, err, shard.ErrInlineNotAllowed)
+		_, err = shard.Unmarshal(b, shard.WithStrict(), shard.WithPailMaxInlineSize(4))
+		require.ErrorIs(t, err, shard.ErrInlineTooLarge)
+		_, err = shard.Unmarshal(b, shard.WithStrict(), shard.WithPailMaxInlineSize(64))
+		require.NoError(t, err)
+	})
+}
+
+func inlineLink(t *testing.T, n datamodel.Node) ipld.Link {
+	l, err := shard.InlineLink(n)
+	require.NoError(t, err)
+	return l
+}
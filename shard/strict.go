@@ -0,0 +1,273 @@
+package shard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+)
+
+// Errors returned by [Unmarshal] and [UnmarshalRoot] when decoding a shard that
+// is not valid in strict mode (see [WithStrict]).
+var (
+	ErrNotCanonical     = errors.New("not canonically encoded")
+	ErrUnknownField     = errors.New("unknown field")
+	ErrMalformedEntry   = errors.New("malformed entry")
+	ErrEmptyKey         = errors.New("empty key")
+	ErrUnsortedEntries  = errors.New("entries are not sorted")
+	ErrDuplicateKey     = errors.New("duplicate key")
+	ErrKeyTooLarge      = errors.New("key exceeds maximum key size")
+	ErrInlineNotAllowed = errors.New("inline values are not allowed")
+	ErrInlineTooLarge   = errors.New("inline value exceeds maximum inline size")
+	ErrInvalidFanout    = errors.New("invalid fanout shard")
+	ErrVersionMismatch  = errors.New("shard version does not match pail version")
+)
+
+// UnmarshalOption configures decoding by [Unmarshal] and [UnmarshalRoot].
+type UnmarshalOption func(*unmarshalOptions)
+
+type unmarshalOptions struct {
+	strict        bool
+	maxKeySize    int64
+	maxInlineSize int64
+	maxShardSize  int64
+	version       int64
+}
+
+// WithStrict enables strict decoding, which rejects shards that could not
+// have been created by this library. This is intended for blocks received
+// from untrusted peers. A shard is rejected if:
+//
+//   - it is not canonically encoded DAG-CBOR ([ErrNotCanonical]).
+//   - it has fields other than those of its kind and version
+//     ([ErrUnknownField]).
+//   - it is a non-root shard of a different version to the pail, when the
+//     version of the pail is passed with [WithPailVersion]
+//     ([ErrVersionMismatch]).
+//   - an entry or value has an unexpected structure ([ErrMalformedEntry]).
+//   - an entry of a non-root shard has an empty key ([ErrEmptyKey]), other
+//     than in a part of the root shard (see [WithFanout]).
+//   - entries are not in ascending key order ([ErrUnsortedEntries]), or two
+//     entries have the same key ([ErrDuplicateKey]).
+//   - the prefix and key of an entry exceed the maximum key size of the pail
+//     ([ErrKeyTooLarge]).
+//   - a value is stored inline in a pail that does not declare a maximum
+//     inline size ([ErrInlineNotAllowed]), or exceeds it
+//     ([ErrInlineTooLarge]). Non-root shards are only checked if the maximum
+//     inline size of the pail is passed with [WithPailMaxInlineSize].
+//   - it is a fanout shard in a pail that does not declare a maximum shard
+//     size, or its entries are not links to parts keyed as described by
+//     [WithFanout] ([ErrInvalidFanout]). Non-root shards are only checked
+//     against the maximum shard size of the pail if it is passed with
+//     [WithPailMaxShardSize].
+//
+// Root shards must be decoded with [UnmarshalRoot] in strict mode.
+func WithStrict() UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.strict = true
+	}
+}
+
+// WithPailMaxKeySize sets the maximum key size of the pail the shard belongs
+// to, which is checked when decoding non-root shards in strict mode. Root
+// shards are checked against the maximum key size they declare. The default
+// is [MaxKeySize].
+func WithPailMaxKeySize(size int64) UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.maxKeySize = size
+	}
+}
+
+// WithPailMaxInlineSize sets the maximum inline size of the pail the shard
+// belongs to, which is 0 if the pail does not allow inline values. Inline
+// values of non-root shards are checked against it when decoding in strict
+// mode. Root shards are checked against the maximum inline size they declare.
+func WithPailMaxInlineSize(size int64) UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.maxInlineSize = size
+	}
+}
+
+// WithPailVersion sets the version of the pail the shard belongs to. Non-root
+// shards are rejected when decoding in strict mode if they were encoded for a
+// different version, including shards without an encoded version in pails
+// whose versions encode it in every shard. Root shards declare the version of
+// the pail.
+func WithPailVersion(version int64) UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.version = version
+	}
+}
+
+// WithPailMaxShardSize sets the maximum shard size of the pail the shard
+// belongs to, which is 0 if the pail does not split shards by size. Non-root
+// fanout shards are rejected when decoding in strict mode if it is 0. Root
+// shards are checked against the maximum shard size they declare.
+func WithPailMaxShardSize(size int64) UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.maxShardSize = size
+	}
+}
+
+func newUnmarshalOptions(opts []UnmarshalOption) unmarshalOptions {
+	o := unmarshalOptions{maxKeySize: MaxKeySize, maxInlineSize: -1, maxShardSize: -1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// checkCanonical checks that the bytes a node was decoded from are the same as
+// the canonical encoding of the node.
+func checkCanonical(n datamodel.Node, b []byte) error {
+	buf := bytes.NewBuffer([]byte{})
+	err := dagcbor.Encode(n, buf)
+	if err != nil {
+		return fmt.Errorf("CBOR encoding: %w", err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		return ErrNotCanonical
+	}
+	return nil
+}
+
+// checkFields checks that the map node has only the allowed fields.
+func checkFields(n datamodel.Node, allowed ...string) error {
+	fields := n.MapIterator()
+	if fields == nil {
+		return errors.New("shard is not a map")
+	}
+	for !fields.Done() {
+		kn, _, err := fields.Next()
+		if err != nil {
+			return fmt.Errorf("iterating fields: %w", err)
+		}
+		k, err := kn.AsString()
+		if err != nil {
+			return fmt.Errorf("decoding field name as string: %w", err)
+		}
+		if !slices.Contains(allowed, k) {
+			return fmt.Errorf("%w: %q", ErrUnknownField, k)
+		}
+	}
+	return nil
+}
+
+// checkEntryNode checks the structure of an encoded entry, which must be a
+// key and a value, where the value is a link to user data, inline data in the
+// form {"inline": data}, or a list of a shard link and optionally a link to
+// user data or inline data.
+func checkEntryNode(n datamodel.Node) error {
+	if n.Kind() != datamodel.Kind_List || n.Length() != 2 {
+		return fmt.Errorf("%w: entry is not a key and value", ErrMalformedEntry)
+	}
+	vn, err := n.LookupByIndex(1)
+	if err != nil {
+		return fmt.Errorf("looking up value: %w", err)
+	}
+	if vn.Kind() != datamodel.Kind_List {
+		return checkValueNode(vn)
+	}
+	if vn.Length() < 1 || vn.Length() > 2 {
+		return fmt.Errorf("%w: shard value has %d elements", ErrMalformedEntry, vn.Length())
+	}
+	sn, err := vn.LookupByIndex(0)
+	if err != nil {
+		return fmt.Errorf("looking up shard link: %w", err)
+	}
+	if sn.Kind() != datamodel.Kind_Link {
+		return fmt.Errorf("%w: shard value is not a link", ErrMalformedEntry)
+	}
+	if vn.Length() == 2 {
+		dn, err := vn.LookupByIndex(1)
+		if err != nil {
+			return fmt.Errorf("looking up value: %w", err)
+		}
+		return checkValueNode(dn)
+	}
+	return nil
+}
+
+// checkValueNode checks that an encoded value is a link to user data or inline
+// data in the form {"inline": data}.
+func checkValueNode(n datamodel.Node) error {
+	switch n.Kind() {
+	case datamodel.Kind_Link:
+		return nil
+	case datamodel.Kind_Map:
+		if n.Length() == 1 {
+			if _, err := n.LookupByString(inlineKey); err == nil {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: value is not a link or inline data", ErrMalformedEntry)
+}
+
+// checkInline checks the values stored inline in entries against the maximum
+// inline size of the pail, which is 0 if inline values are not allowed.
+func checkInline(prefix string, entries []Entry, maxInlineSize int64) error {
+	for _, e := range entries {
+		v := e.Value().Value()
+		if v == nil || !IsInline(v) {
+			continue
+		}
+		if maxInlineSize == 0 {
+			return fmt.Errorf("%w: %q", ErrInlineNotAllowed, prefix+e.Key())
+		}
+		size, err := InlineSize(v)
+		if err != nil {
+			return err
+		}
+		if int64(size) > maxInlineSize {
+			return fmt.Errorf("%w: %q exceeds %d bytes", ErrInlineTooLarge, prefix+e.Key(), maxInlineSize)
+		}
+	}
+	return nil
+}
+
+// checkEntries checks that the entries of a shard with the passed prefix are
+// in strictly ascending key order and do not exceed the maximum key size. An
+// entry may only have an empty key if emptyKey is true.
+func checkEntries(prefix string, entries []Entry, maxKeySize int64, emptyKey bool) error {
+	for i, e := range entries {
+		if e.Key() == "" && !emptyKey {
+			return ErrEmptyKey
+		}
+		if int64(len(prefix)+len(e.Key())) > maxKeySize {
+			return fmt.Errorf("%w: %q exceeds %d bytes", ErrKeyTooLarge, prefix+e.Key(), maxKeySize)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := entries[i-1].Key()
+		if e.Key() == prev {
+			return fmt.Errorf("%w: %q", ErrDuplicateKey, prefix+e.Key())
+		}
+		if e.Key() < prev {
+			return fmt.Errorf("%w: %q before %q", ErrUnsortedEntries, prefix+prev, prefix+e.Key())
+		}
+	}
+	return nil
+}
+
+// checkFanout checks that the entries of a fanout shard are links to parts
+// without values, where only the first entry has an empty key. Entries are
+// checked to be in ascending key order by [checkEntries].
+func checkFanout(entries []Entry) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("%w: no entries", ErrInvalidFanout)
+	}
+	for i, e := range entries {
+		if e.Value().Shard() == nil || e.Value().Value() != nil {
+			return fmt.Errorf("%w: entry %d is not a link to a part", ErrInvalidFanout, i)
+		}
+		if (i == 0) != (e.Key() == "") {
+			return fmt.Errorf("%w: only the first entry may have an empty key", ErrInvalidFanout)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package shard_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime/codec"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalStrict(t *testing.T) {
+	link := testutil.RandomLink(t)
+
+	t.Run("canonical shards", func(t *testing.T) {
+		shards := []shard.Shard{
+			shard.New("pfx", []shard.Entry{
+				shard.NewEntry("a", shard.NewValue(link, nil)),
+				shard.NewEntry("b", shard.NewValue(nil, link)),
+				shard.NewEntry("c", shard.NewValue(link, link)),
+			}),
+			shard.New("pfx", []shard.Entry{
+				shard.NewEntry("a", shard.NewValue(link, nil)),
+			}, shard.WithShardVersion(shard.Version3)),
+			shard.NewBinary("\xff", []shard.Entry{
+				shard.NewEntry("\x00", shard.NewValue(link, nil)),
+			}),
+		}
+		for _, s := range shards {
+			b, err := shard.Marshal(s)
+			require.NoError(t, err)
+			d, err := shard.Unmarshal(b, shard.WithStrict())
+			require.NoError(t, err)
+			require.Equal(t, s, d)
+		}
+
+		// the empty key is allowed in the root shard
+		r := shard.NewRoot([]shard.Entry{
+			shard.NewEntry("", shard.NewValue(link, nil)),
+			shard.NewEntry("a", shard.NewValue(link, nil)),
+		}, shard.WithMaxInlineSize(64), shard.WithMaxShardSize(1024))
+		b, err := shard.Marshal(r)
+		require.NoError(t, err)
+		d, err := shard.UnmarshalRoot(b, shard.WithStrict())
+		require.NoError(t, err)
+		require.Equal(t, r, d)
+	})
+
+	t.Run("root shard decoded as a shard", func(t *testing.T) {
+		b, err := shard.Marshal(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		_, err = shard.Unmarshal(b)
+		require.NoError(t, err)
+		_, err = shard.Unmarshal(b, shard.WithStrict())
+		require.ErrorIs(t, err, shard.ErrUnknownField)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		b := encodeShard(t, "pfx", []entryNode{{"a", qp.Link(link)}}, func(ma datamodel.MapAssembler) {
+			qp.MapEntry(ma, "extra", qp.Int(1))
+		})
+		_, err := shard.Unmarshal(b)
+		require.NoError(t, err)
+		_, err = shard.Unmarshal(b, shard.WithStrict())
+		require.ErrorIs(t, err, shard.ErrUnknownField)
+
+		// version 2 shards do not encode the version
+		b = encodeShard(t, "pfx", nil, func(ma datamodel.MapAssembler) {
+			qp.MapEntry(ma, "version", qp.Int(shard.Version2))
+		})
+		_, err = shard.Unmarshal(b, shard.WithStrict())
+		require.ErrorIs(t, err, shard.ErrUnknownField)
+	})
+
+	t.Run("not canonical", func(t *testing.T) {
+		n, err := qp.BuildMap(basicnode.Prototype.Any, 2, func(ma datamodel.MapAssembler) {
+			// canonical order is shortest key first
+			qp.MapEntry(ma, "entries", qp.List(0, func(la datamodel.ListAssembler) {}))
+			qp.MapEntry(ma, "prefix", qp.String("pfx"))
+		})
+		require.NoError(t, err)
+		buf := bytes.NewBuffer([]byte{})
+		err = dagcbor.EncodeOptions{
+			AllowLinks:  true,
+			MapSortMode: codec.MapSortMode_None,
+		}.Encode(n, buf)
+		require.NoError(t, err)
+
+		_, err = shard.Unmarshal(buf.Bytes())
+		require.NoError(t, err)
+		_, err = shard.Unmarshal(buf.Bytes(), shard.WithStrict())
+		require.ErrorIs(t, err, shard.ErrNotCanonical)
+	})
+
+	t.Run("malformed entry", func(t *testing.T) {
+		b := encodeShard(t, "pfx", []entryNode{
+			{"a", qp.List(3, func(la datamodel.ListAssembler) {
+				qp.ListEntry(la, qp.Link(link))
+				qp.ListEntry(la, qp.Link(link))
+				qp.ListEntry(la, qp.Link(link))
+			})},
+		}, nil)
+		_, err := shard.Unmarshal(b)
+		require.NoError(t, err)
+		_, err = shard.Unmarshal(b, shard.WithStrict())
+		require.ErrorIs(t, err, shard.ErrMalformedEntry)
+	})
+
+	t.Run("invalid entries", func(t *testing.T) {
+		vectors := []struct {
+			name    string
+			entries []entryNode
+			err     error
+		}{
+			{"empty key", []entryNode{{"", qp.Link(link)}}, shard.ErrEmptyKey},
+			{"unsorted", []entryNode{{"b", qp.Link(link)}, {"a", qp.Link(link)}}, shard.ErrUnsortedEntries},
+			{"duplicate", []entryNode{{"a", qp.Link(link)}, {"a", qp.Link(link)}}, shard.ErrDuplicateKey},
+		}
+		for _, v := range vectors {
+			t.Run(v.name, func(t *testing.T) {
+				b := encodeShard(t, "pfx", v.entries, nil)
+				_, err := shard.Unmarshal(b)
+				require.NoError(t, err)
+				_, err = shard.Unmarshal(b, shard.WithStrict())
+				require.ErrorIs(t, err, v.err)
+			})
+		}
+	})
+
+	t.Run("key too large", func(t *testing.T) {
+		b := encodeShard(t, "pfx", []entryNode{{"abc", qp.Link(link)}}, nil)
+		_, err := shard.Unmarshal(b, shard.WithStrict(), shard.WithPailMaxKeySize(6))
+		require.NoError(t, err)
+		_, err = shard.Unmarshal(b, shard.WithStrict(), shard.WithPailMaxKeySize(5))
+		require.ErrorIs(t, err, shard.ErrKeyTooLarge)
+
+		r, err := shard.Marshal(shard.NewRoot([]shard.Entry{
+			shard.NewEntry("abc", shard.NewValue(link, nil)),
+		}, shard.WithMaxKeySize(2)))
+		require.NoError(t, err)
+		_, err = shard.UnmarshalRoot(r, shard.WithStrict())
+		require.ErrorIs(t, err, shard.ErrKeyTooLarge)
+	})
+
+	t.Run("fanout", func(t *testing.T) {
+		part := func(key string) entryNode {
+			return entryNode{key, qp.List(1, func(la datamodel.ListAssembler) {
+				qp.ListEntry(la, qp.Link(link))
+			})}
+		}
+		fanout := func(ma datamodel.MapAssembler) {
+			qp.MapEntry(ma, "fanout", qp.Bool(true))
+		}
+
+		s := shard.New("pfx", []shard.Entry{
+			shard.NewEntry("", shard.NewValue(nil, link)),
+			shard.NewEntry("b", shard.NewValue(nil, link)),
+		}, shard.WithFanout())
+		b, err := shard.Marshal(s)
+		require.NoError(t, err)
+		d, err := shard.Unmarshal(b, shard.WithStrict(), shard.WithPailMaxShardSize(1024))
+		require.NoError(t, err)
+		require.Equal(t, s, d)
+		require.True(t, shard.IsFanout(d))
+
+		// fanout shards are only allowed in pails with a maximum shard size
+		_, err = shard.Unmarshal(b, shard.WithStrict(), shard.WithPailMaxShardSize(0))
+		require.ErrorIs(t, err, shard.ErrInvalidFanout)
+		r, err := shard.Marshal(shard.NewRoot(s.Entries(), shard.WithRootFanout()))
+		require.NoError(t, err)
+		_, err = shard.UnmarshalRoot(r, shard.WithStrict())
+		require.ErrorIs(t, err, shard.ErrInvalidFanout)
+
+		vectors := []struct {
+			name    string
+			entries []entryNode
+		}{
+			{"value", []entryNode{part(""), {"b", qp.Link(link)}}},
+			{"first key not empty", []entryNode{part("a"), part("b")}},
+			{"no entries", nil},
+		}
+		for _, v := range vectors {
+			t.Run(v.name, func(t *testing.T) {
+				b := encodeShard(t, "pfx", v.entries, fanout)
+				_, err := shard.Unmarshal(b)
+				require.NoError(t, err)
+				_, err = shard.Unmarshal(b, shard.WithStrict())
+				require.ErrorIs(t, err, shard.ErrInvalidFanout)
+			})
+		}
+
+		// a fanout field is only encoded for fanout shards
+		b = encodeShard(t, "pfx", []entryNode{{"a", qp.Link(link)}}, func(ma datamodel.MapAssembler) {
+			qp.MapEntry(ma, "fanout", qp.Bool(false))
+		})
+		d, err = shard.Unmarshal(b)
+		require.NoError(t, err)
+		require.False(t, shard.IsFanout(d))
+		_, err = shard.Unmarshal(b, shard.WithStrict())
+		require.ErrorIs(t, err, shard.ErrUnknownField)
+	})
+
+	t.Run("version", func(t *testing.T) {
+		v2, err := shard.Marshal(shard.New("pfx", []shard.Entry{
+			shard.NewEntry("a", shard.NewValue(link, nil)),
+		}))
+		require.NoError(t, err)
+		v3, err := shard.Marshal(shard.New("pfx", []shard.Entry{
+			shard.NewEntry("a", shard.NewValue(link, nil)),
+		}, shard.WithShardVersion(shard.Version3)))
+		require.NoError(t, err)
+
+		_, err = shard.Unmarshal(v2, shard.WithStrict(), shard.WithPailVersion(shard.Version2))
+		require.NoError(t, err)
+		_, err = shard.Unmarshal(v3, shard.WithStrict(), shard.WithPailVersion(shard.Version3))
+		require.NoError(t, err)
+
+		_, err = shard.Unmarshal(v2, shard.WithStrict(), shard.WithPailVersion(shard.Version3))
+		require.ErrorIs(t, err, shard.ErrVersionMismatch)
+		_, err = shard.Unmarshal(v3, shard.WithStrict(), shard.WithPailVersion(shard.Version2))
+		require.ErrorIs(t, err, shard.ErrVersionMismatch)
+
+		// the version is only checked in strict mode
+		_, err = shard.Unmarshal(v2, shard.WithPailVersion(shard.Version3))
+		require.NoError(t, err)
+	})
+
+	t.Run("fetcher", func(t *testing.T) {
+		ctx := context.Background()
+		bs := testutil.NewBlockstore()
+
+		b := encodeShard(t, "pfx", []entryNode{{"b", qp.Link(link)}, {"a", qp.Link(link)}}, nil)
+		blk := block.New(testutil.RandomLink(t), b)
+		err := bs.Put(ctx, blk)
+		require.NoError(t, err)
+
+		_, err = shard.NewFetcher(bs).Get(ctx, blk.Link())
+		require.NoError(t, err)
+		_, err = shard.NewFetcher(bs, shard.WithStrict()).Get(ctx, blk.Link())
+		require.ErrorIs(t, err, shard.ErrUnsortedEntries)
+	})
+}
+
+type entryNode struct {
+	key   string
+	value qp.Assemble
+}
+
+// encodeShard encodes a shard with the passed prefix and entries, which are
+// not checked, and any extra fields.
+func encodeShard(t *testing.T, prefix string, entries []entryNode, extra func(ma datamodel.MapAssembler)) []byte {
+	n, err := qp.BuildMap(basicnode.Prototype.Any, -1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "prefix", qp.String(prefix))
+		qp.MapEntry(ma, "entries", qp.List(int64(len(entries)), func(la datamodel.ListAssembler) {
+			for _, e := range entries {
+				qp.ListEntry(la, qp.List(2, func(la datamodel.ListAssembler) {
+					qp.ListEntry(la, qp.String(e.key))
+					qp.ListEntry(la, e.value)
+				}))
+			}
+		}))
+		if extra != nil {
+			extra(ma)
+		}
+	})
+	require.NoError(t, err)
+	buf := bytes.NewBuffer([]byte{})
+	err = dagcbor.Encode(n, buf)
+	require.NoError(t, err)
+	return buf.Bytes()
+}
@@ -0,0 +1,45 @@
+package shard
+
+import (
+	"maps"
+	"slices"
+)
+
+// Version is the default shard compatibility version, used for pails created
+// without the [WithVersion] option.
+const Version = Version2
+
+const (
+	// Version2 shards encode the version in the root shard only.
+	Version2 = 2
+	// Version3 shards encode the version in every shard, so that any shard can
+	// be decoded without the root shard.
+	Version3 = 3
+)
+
+// format describes how shards of a particular version are encoded.
+type format struct {
+	// shardVersion indicates the version is encoded in non-root shards, as well
+	// as in the root shard.
+	shardVersion bool
+}
+
+// formats is the registry of supported versions. Shards of every supported
+// version can be decoded, so pails of older versions can be read alongside
+// newer ones.
+var formats = map[int64]format{
+	Version2: {},
+	Version3: {shardVersion: true},
+}
+
+// SupportedVersion returns true if shards of the passed version can be
+// encoded and decoded.
+func SupportedVersion(version int64) bool {
+	_, ok := formats[version]
+	return ok
+}
+
+// SupportedVersions returns the supported versions in ascending order.
+func SupportedVersions() []int64 {
+	return slices.Sorted(maps.Keys(formats))
+}
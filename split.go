@@ -0,0 +1,232 @@
+package pail
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/shard"
+)
+
+// encodeShard encodes a non-root shard with the passed prefix and entries,
+// which is a fanout shard if fanout is true. A shard that exceeds the maximum
+// shard size of the pail is split into parts (see [shard.WithFanout]) by
+// [partition]. Multi-byte characters are never split, so prefixes and fanout
+// keys of pails with text keys remain valid UTF-8.
+//
+// It returns the fanout entries that link to the shard, or to its parts if it
+// was split, the first of which has an empty key, and the blocks that were
+// created, children before parents.
+func encodeShard(rshard shard.RootShard, prefix string, fanout bool, entries []shard.Entry) ([]shard.Entry, []shard.BlockView, error) {
+	blk, err := marshalShard(rshard, prefix, fanout, entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	if exceeds(rshard, blk) {
+		parts, err := partition(rshard, prefix, fanout, entries)
+		if err != nil {
+			return nil, nil, err
+		}
+		if splittable(fanout, entries, parts) {
+			return encodeParts(rshard, prefix, fanout, parts)
+		}
+	}
+	return []shard.Entry{partEntry("", blk.Link())}, []shard.BlockView{blk}, nil
+}
+
+// encodeRoot encodes the root shard with the passed entries, which is a fanout
+// shard if fanout is true. A root shard that exceeds the maximum shard size of
+// the pail is split into parts, and becomes a fanout shard that links to them.
+// If there are too many parts for the root shard, they are linked from fanout
+// shards in between.
+//
+// It returns the blocks that were created, children before parents, so the
+// last block is the root shard.
+func encodeRoot(rshard shard.RootShard, fanout bool, entries []shard.Entry) ([]shard.BlockView, error) {
+	var blocks []shard.BlockView
+	for {
+		var opts []shard.RootOption
+		if fanout {
+			opts = append(opts, shard.WithRootFanout())
+		}
+		blk, err := shard.MarshalBlock(newRoot(rshard, entries, opts...))
+		if err != nil {
+			return nil, err
+		}
+		if !exceeds(rshard, shard.AsBlock(blk)) {
+			return append(blocks, shard.AsBlock(blk)), nil
+		}
+		parts, err := partition(rshard, "", fanout, entries)
+		if err != nil {
+			return nil, err
+		}
+		if !splittable(fanout, entries, parts) {
+			return append(blocks, shard.AsBlock(blk)), nil
+		}
+		links, children, err := encodeParts(rshard, "", fanout, parts)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, children...)
+		fanout, entries = true, links
+	}
+}
+
+// joinParts returns a link to a single shard with the passed prefix for the
+// passed fanout entries, as returned by [encodeShard]. This is the shard the
+// only entry links to, otherwise a fanout shard that links to the parts, with
+// further fanout shards in between if there are too many parts for one. It
+// also returns the blocks that were created, children before parents.
+func joinParts(rshard shard.RootShard, prefix string, links []shard.Entry) (ipld.Link, []shard.BlockView, error) {
+	var blocks []shard.BlockView
+	for len(links) > 1 {
+		next, children, err := encodeShard(rshard, prefix, true, links)
+		if err != nil {
+			return nil, nil, err
+		}
+		blocks = append(blocks, children...)
+		links = next
+	}
+	return links[0].Value().Shard(), blocks, nil
+}
+
+// encodeParts encodes each of the passed parts of a shard with the passed
+// prefix, returning the fanout entries that link to them and the blocks that
+// were created, children before parents. A part that still exceeds the maximum
+// shard size is split further, and linked by more than one fanout entry.
+func encodeParts(rshard shard.RootShard, prefix string, fanout bool, parts [][]shard.Entry) ([]shard.Entry, []shard.BlockView, error) {
+	var links []shard.Entry
+	var blocks []shard.BlockView
+	for i, p := range parts {
+		plinks, children, err := encodeShard(rshard, prefix, fanout, p)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i > 0 {
+			plinks[0] = partEntry(splitKey(rshard, p[0].Key())[0], plinks[0].Value().Shard())
+		}
+		links = append(links, plinks...)
+		blocks = append(blocks, children...)
+	}
+	return links, blocks, nil
+}
+
+// partition divides the entries of a shard with the passed prefix into runs
+// of entries that do not exceed the maximum shard size of the pail when
+// encoded as a part. Entries that start with the same character (see
+// [splitKey]) are kept in the same part, so that a key belongs to the part
+// that holds any entry it shares a prefix with.
+func partition(rshard shard.RootShard, prefix string, fanout bool, entries []shard.Entry) ([][]shard.Entry, error) {
+	empty, err := marshalShard(rshard, prefix, fanout, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the header of the entries list grows with the number of entries
+	limit := int(rshard.MaxShardSize()) - len(empty.Bytes()) - listHeaderSize
+
+	var parts [][]shard.Entry
+	var size, start int
+	for i := 0; i < len(entries); {
+		j := i + 1
+		if char := firstChar(rshard, entries[i].Key()); char != "" {
+			for j < len(entries) && strings.HasPrefix(entries[j].Key(), char) {
+				j++
+			}
+		}
+		var gsize int
+		for _, e := range entries[i:j] {
+			esize, err := entrySize(rshard, prefix, fanout, e, len(empty.Bytes()))
+			if err != nil {
+				return nil, err
+			}
+			gsize += esize
+		}
+		if i > start && size+gsize > limit {
+			parts = append(parts, entries[start:i])
+			start, size = i, 0
+		}
+		size += gsize
+		i = j
+	}
+	return append(parts, entries[start:]), nil
+}
+
+// splittable determines if the passed parts reduce the shard they were
+// partitioned from. A shard with entries that cannot be divided is not split,
+// and neither is a fanout shard that would link to a part for each of its
+// entries.
+func splittable(fanout bool, entries []shard.Entry, parts [][]shard.Entry) bool {
+	return len(parts) > 1 && !(fanout && len(parts) == len(entries))
+}
+
+// listHeaderSize is the maximum size in bytes of the CBOR header of a list,
+// which grows with the number of items in the list.
+const listHeaderSize = 9
+
+// entrySize returns the encoded size of an entry of a shard with the passed
+// prefix, given the encoded size of the shard without entries.
+func entrySize(rshard shard.RootShard, prefix string, fanout bool, e shard.Entry, emptySize int) (int, error) {
+	blk, err := marshalShard(rshard, prefix, fanout, []shard.Entry{e})
+	if err != nil {
+		return 0, err
+	}
+	return len(blk.Bytes()) - emptySize, nil
+}
+
+// exceeds determines if the encoded shard exceeds the maximum shard size of
+// the pail.
+func exceeds(rshard shard.RootShard, blk shard.BlockView) bool {
+	return rshard.MaxShardSize() > 0 && int64(len(blk.Bytes())) > rshard.MaxShardSize()
+}
+
+// marshalShard encodes a non-root shard with the passed prefix and entries,
+// which is a fanout shard if fanout is true.
+func marshalShard(rshard shard.RootShard, prefix string, fanout bool, entries []shard.Entry) (shard.BlockView, error) {
+	var opts []shard.Option
+	if fanout {
+		opts = append(opts, shard.WithFanout())
+	}
+	blk, err := shard.MarshalBlock(newShard(rshard, prefix, entries, opts...))
+	if err != nil {
+		return nil, fmt.Errorf("marshalling shard: %w", err)
+	}
+	return blk, nil
+}
+
+// partEntry creates a fanout entry with the passed key that links to a part.
+func partEntry(key string, part ipld.Link) shard.Entry {
+	return shard.NewEntry(key, shard.NewValue(nil, part))
+}
+
+// firstChar returns the first character of the key (see [splitKey]), which is
+// empty for an empty key.
+func firstChar(rshard shard.RootShard, key string) string {
+	if key == "" {
+		return ""
+	}
+	return key[:charLen(rshard, key)]
+}
+
+// route returns the index of the entry of a fanout shard that links to the
+// part the key belongs to, relative to the prefix of the shard. This is the
+// last entry with a key that is less than or equal to it.
+func route(entries []shard.Entry, key string) int {
+	i, _ := slices.BinarySearchFunc(entries, key, func(e shard.Entry, key string) int {
+		if e.Key() <= key {
+			return -1
+		}
+		return 1
+	})
+	return max(i-1, 0)
+}
+
+// partBounds returns the range of keys, relative to the prefix of a fanout
+// shard, that belong to the part linked by its entry at index i. Keys are at
+// least lower, and less than upper unless the part is the last.
+func partBounds(entries []shard.Entry, i int) (lower string, upper string, last bool) {
+	if i == len(entries)-1 {
+		return entries[i].Key(), "", true
+	}
+	return entries[i].Key(), entries[i+1].Key(), false
+}
@@ -0,0 +1,349 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitShards(t *testing.T) {
+	ctx := context.Background()
+
+	// keys made of two multi-byte characters, 100 keys for each first character
+	var objects []object
+	for i := range 5000 {
+		objects = append(objects, object{fmt.Sprintf("%c%c/file.txt", 0x4e00+i%50, 0x4e00+i/50), testutil.RandomLink(t)})
+	}
+	sorted := slices.Clone(objects)
+	slices.SortFunc(sorted, objectKeySort)
+
+	// keys with a unique first character, so they have no common prefixes
+	var unique []object
+	for i := range 5000 {
+		unique = append(unique, object{fmt.Sprintf("%c/file.txt", 0x5000+i), testutil.RandomLink(t)})
+	}
+	sortedUnique := slices.Clone(unique)
+	slices.SortFunc(sortedUnique, objectKeySort)
+
+	t.Run("multi-byte characters", func(t *testing.T) {
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsUTF8), shard.WithMaxShardSize(4*1024))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := putAll(t, bs, rb0.Link(), objects)
+		require.LessOrEqual(t, maxShardBytes(t, bs, r0), 4*1024)
+		require.Greater(t, countFanouts(t, bs, r0), 0)
+		requireTextShards(t, bs, r0)
+
+		for _, o := range objects {
+			v, err := Get(ctx, bs, r0, o.key)
+			require.NoError(t, err)
+			require.Equal(t, o.value, v)
+		}
+		require.Equal(t, entriesOf(sorted), collectEntries(t, bs, r0))
+
+		// entries of a single multi-byte character prefix
+		prefix := fmt.Sprintf("%c", 0x4e00+10)
+		var keys []string
+		for e, err := range Entries(ctx, bs, r0, WithKeyPrefix(prefix)) {
+			require.NoError(t, err)
+			keys = append(keys, e.Key)
+		}
+		require.Len(t, keys, 100)
+		for _, k := range keys {
+			require.True(t, strings.HasPrefix(k, prefix))
+		}
+
+		r1 := r0
+		for _, o := range objects[:2500] {
+			var diff shard.Diff
+			r1, diff, err = Del(ctx, bs, r1, o.key)
+			require.NoError(t, err)
+			testutil.ApplyDiff(t, diff, bs)
+		}
+		remaining := slices.Clone(objects[2500:])
+		slices.SortFunc(remaining, objectKeySort)
+		require.Equal(t, entriesOf(remaining), collectEntries(t, bs, r1))
+	})
+
+	t.Run("not split without max shard size", func(t *testing.T) {
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsUTF8))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := putAll(t, bs, rb0.Link(), unique[:1000])
+		require.Equal(t, []string{r0.String()}, reachable(t, bs, r0))
+		require.Greater(t, maxShardBytes(t, bs, r0), 16*1024)
+	})
+
+	t.Run("long keys", func(t *testing.T) {
+		rb0, err := New(shard.WithMaxShardSize(8 * 1024))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		// every printable ASCII character, followed by a long suffix
+		var objects []object
+		for c := byte(0x20); c <= 0x7e; c++ {
+			objects = append(objects, object{string(c) + strings.Repeat("x", 2000), testutil.RandomLink(t)})
+		}
+		r0 := putAll(t, bs, rb0.Link(), objects)
+		require.LessOrEqual(t, maxShardBytes(t, bs, r0), 8*1024)
+		require.Equal(t, entriesOf(objects), collectEntries(t, bs, r0))
+	})
+
+	t.Run("binary keys", func(t *testing.T) {
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsBinary), shard.WithMaxShardSize(16*1024))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		var objects []object
+		for i := range 256 {
+			objects = append(objects, object{string([]byte{byte(i)}) + string(testutil.RandomBytes(t, 128)), testutil.RandomLink(t)})
+		}
+		r0 := putAll(t, bs, rb0.Link(), objects)
+		require.LessOrEqual(t, maxShardBytes(t, bs, r0), 16*1024)
+		require.Equal(t, entriesOf(objects), collectEntries(t, bs, r0))
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsUTF8), shard.WithMaxShardSize(4*1024))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		batch, err := NewBatcher(ctx, bs, rb0.Link())
+		require.NoError(t, err)
+		for _, o := range objects {
+			err = batch.Put(ctx, o.key, o.value)
+			require.NoError(t, err)
+		}
+		r0, diff, err := batch.Commit()
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+
+		require.LessOrEqual(t, maxShardBytes(t, bs, r0), 4*1024)
+		require.Greater(t, countFanouts(t, bs, r0), 0)
+		requireTextShards(t, bs, r0)
+		require.Equal(t, entriesOf(sorted), collectEntries(t, bs, r0))
+	})
+
+	t.Run("from entries", func(t *testing.T) {
+		bs := testutil.NewBlockstore()
+		r0, err := FromEntries(ctx, objectEntries(sorted), func(b shard.BlockView) error {
+			return bs.Put(ctx, b)
+		}, shard.WithKeyChars(shard.KeyCharsUTF8), shard.WithMaxShardSize(4*1024))
+		require.NoError(t, err)
+
+		require.LessOrEqual(t, maxShardBytes(t, bs, r0), 4*1024)
+		require.Greater(t, countFanouts(t, bs, r0), 0)
+		requireTextShards(t, bs, r0)
+		require.Equal(t, entriesOf(sorted), collectEntries(t, bs, r0))
+
+		// and the pail can be modified
+		v := testutil.RandomLink(t)
+		r1, diff, err := Put(ctx, bs, r0, objects[0].key, v)
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+
+		v0, err := Get(ctx, bs, r1, objects[0].key)
+		require.NoError(t, err)
+		require.Equal(t, v, v0)
+	})
+
+	t.Run("unique first characters", func(t *testing.T) {
+		rb0, err := New(shard.WithKeyChars(shard.KeyCharsUTF8), shard.WithMaxShardSize(16*1024))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		// the root shard is split into parts, since the keys cannot be moved into
+		// child shards
+		r0 := putAll(t, bs, rb0.Link(), unique)
+		require.LessOrEqual(t, maxShardBytes(t, bs, r0), 16*1024)
+		rs, err := shard.NewFetcher(bs).GetRoot(ctx, r0)
+		require.NoError(t, err)
+		require.True(t, shard.IsFanout(rs.Value()))
+		requireTextShards(t, bs, r0)
+
+		problems, err := Verify(ctx, bs, r0)
+		require.NoError(t, err)
+		require.Empty(t, problems)
+
+		for _, o := range unique {
+			v, err := Get(ctx, bs, r0, o.key)
+			require.NoError(t, err)
+			require.Equal(t, o.value, v)
+		}
+		require.Equal(t, entriesOf(sortedUnique), collectEntries(t, bs, r0))
+
+		var keys []string
+		for _, o := range unique[:100] {
+			keys = append(keys, o.key)
+		}
+		var values []object
+		for e, err := range GetMany(ctx, bs, r0, keys) {
+			require.NoError(t, err)
+			values = append(values, object{e.Key, e.Value})
+		}
+		require.Equal(t, sortedUnique[:100], values)
+
+		// listing skips parts outside the range
+		var listed []object
+		for e, err := range Entries(ctx, bs, r0, WithKeyGreaterThanOrEqual(sortedUnique[1000].key), WithKeyLessThan(sortedUnique[3000].key), WithReverse()) {
+			require.NoError(t, err)
+			listed = append(listed, object{e.Key, e.Value})
+		}
+		slices.Reverse(listed)
+		require.Equal(t, sortedUnique[1000:3000], listed)
+
+		proof, err := Prove(ctx, bs, r0, unique[1234].key)
+		require.NoError(t, err)
+		require.Greater(t, len(proof.Blocks), 1)
+		v, err := VerifyProof(r0, unique[1234].key, proof)
+		require.NoError(t, err)
+		require.Equal(t, unique[1234].value, v)
+
+		// the same entries are in pails built by a batch or from entries
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+		batch, err := NewBatcher(ctx, bs, rb0.Link())
+		require.NoError(t, err)
+		for _, o := range unique {
+			err = batch.Put(ctx, o.key, o.value)
+			require.NoError(t, err)
+		}
+		r1, diff, err := batch.Commit()
+		require.NoError(t, err)
+		testutil.ApplyDiff(t, diff, bs)
+		require.LessOrEqual(t, maxShardBytes(t, bs, r1), 16*1024)
+		require.Equal(t, entriesOf(sortedUnique), collectEntries(t, bs, r1))
+
+		r2, err := FromEntries(ctx, objectEntries(sortedUnique), func(b shard.BlockView) error {
+			return bs.Put(ctx, b)
+		}, shard.WithKeyChars(shard.KeyCharsUTF8), shard.WithMaxShardSize(16*1024))
+		require.NoError(t, err)
+		require.LessOrEqual(t, maxShardBytes(t, bs, r2), 16*1024)
+		require.Equal(t, entriesOf(sortedUnique), collectEntries(t, bs, r2))
+
+		// deleting entries removes empty parts, the removed shards are kept so
+		// the pails can be compared
+		r3 := r0
+		for _, o := range unique[:4000] {
+			var diff shard.Diff
+			r3, diff, err = Del(ctx, bs, r3, o.key)
+			require.NoError(t, err)
+			for _, b := range diff.Additions {
+				err = bs.Put(ctx, b)
+				require.NoError(t, err)
+			}
+		}
+		require.LessOrEqual(t, maxShardBytes(t, bs, r3), 16*1024)
+		require.Equal(t, entriesOf(sortedUnique[4000:]), collectEntries(t, bs, r3))
+
+		var changes []string
+		for c, err := range Diff(ctx, bs, r0, r3) {
+			require.NoError(t, err)
+			require.Nil(t, c.New)
+			changes = append(changes, c.Key)
+		}
+		require.Len(t, changes, 4000)
+
+		for _, o := range unique[4000:] {
+			var diff shard.Diff
+			r3, diff, err = Del(ctx, bs, r3, o.key)
+			require.NoError(t, err)
+			testutil.ApplyDiff(t, diff, bs)
+		}
+		require.Equal(t, rb0.Link(), r3)
+	})
+
+	t.Run("single character keys", func(t *testing.T) {
+		rb0, err := New(shard.WithMaxShardSize(256))
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		var objects []object
+		for c := byte('a'); c <= 'z'; c++ {
+			objects = append(objects, object{string(c), testutil.RandomLink(t)})
+		}
+		r0 := putAll(t, bs, rb0.Link(), objects)
+		require.Greater(t, len(reachable(t, bs, r0)), 1)
+		require.LessOrEqual(t, maxShardBytes(t, bs, r0), 256)
+		require.Equal(t, entriesOf(objects), collectEntries(t, bs, r0))
+	})
+}
+
+// maxShardBytes returns the size of the largest encoded shard in the pail.
+func maxShardBytes(t *testing.T, blocks block.Fetcher, root ipld.Link) int {
+	s, err := shard.NewFetcher(blocks).Get(context.Background(), root)
+	require.NoError(t, err)
+
+	size := len(s.Bytes())
+	for _, e := range s.Value().Entries() {
+		if e.Value().Shard() != nil {
+			size = max(size, maxShardBytes(t, blocks, e.Value().Shard()))
+		}
+	}
+	return size
+}
+
+// countFanouts returns the number of fanout shards in the pail.
+func countFanouts(t *testing.T, blocks block.Fetcher, root ipld.Link) int {
+	s, err := shard.NewFetcher(blocks).Get(context.Background(), root)
+	require.NoError(t, err)
+
+	var n int
+	if shard.IsFanout(s.Value()) {
+		n++
+	}
+	for _, e := range s.Value().Entries() {
+		if e.Value().Shard() != nil {
+			n += countFanouts(t, blocks, e.Value().Shard())
+		}
+	}
+	return n
+}
+
+// requireTextShards requires that every shard of the pail has a valid UTF-8
+// prefix and encodes its prefix and keys as text.
+func requireTextShards(t *testing.T, blocks block.Fetcher, root ipld.Link) {
+	s, err := shard.NewFetcher(blocks).Get(context.Background(), root)
+	require.NoError(t, err)
+
+	require.True(t, utf8.ValidString(s.Value().Prefix()), "invalid UTF-8 prefix %q", s.Value().Prefix())
+	require.False(t, shard.IsBinary(s.Value()), "shard %q encoded as bytes", s.Value().Prefix())
+	for _, e := range s.Value().Entries() {
+		require.True(t, utf8.ValidString(e.Key()), "invalid UTF-8 key %q", e.Key())
+		if e.Value().Shard() != nil {
+			requireTextShards(t, blocks, e.Value().Shard())
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/shard"
+	"golang.org/x/sync/errgroup"
+)
+
+// Statistics describes the keys and shards of a pail.
+type Statistics struct {
+	// Keys is the total number of keys.
+	Keys int
+	// Shards is the total number of shards.
+	Shards int
+	// MaxDepth is the depth of the deepest shard, where the root shard has
+	// depth 0.
+	MaxDepth int
+	// Bytes is the total size of the encoded shards in bytes.
+	Bytes int
+	// Histogram maps a number of entries to the number of shards that have that
+	// many entries.
+	Histogram map[int]int
+}
+
+type StatsOption func(*statsOptions)
+
+type statsOptions struct {
+	prefix      string
+	concurrency int
+}
+
+// WithStatsKeyPrefix restricts statistics to keys with the given prefix and
+// the shards that contain only keys with the prefix.
+func WithStatsKeyPrefix(prefix string) StatsOption {
+	return func(o *statsOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithStatsConcurrency sets the maximum number of shards that are fetched
+// concurrently. The default is 8.
+func WithStatsConcurrency(n int) StatsOption {
+	return func(o *statsOptions) {
+		o.concurrency = n
+	}
+}
+
+// Stats walks the shards of the pail and reports statistics about the keys
+// and shards it contains.
+func Stats(ctx context.Context, blocks block.Fetcher, root ipld.Link, opts ...StatsOption) (Statistics, error) {
+	o := &statsOptions{concurrency: 8}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.concurrency < 1 {
+		o.concurrency = 1
+	}
+
+	shards := shard.NewFetcher(blocks)
+	rshard, err := shards.GetRoot(ctx, root)
+	if err != nil {
+		return Statistics{}, fmt.Errorf("getting root: %w", err)
+	}
+
+	stats := Statistics{Histogram: map[int]int{}}
+
+	// visit adds the statistics of the shard and returns the links to its child
+	// shards that may contain keys with the prefix.
+	visit := func(s shard.BlockView, depth int) []ipld.Link {
+		var children []ipld.Link
+		// parts of a fanout shard have the same prefix as the shard
+		fanout := shard.IsFanout(s.Value())
+		for _, e := range s.Value().Entries() {
+			key := s.Value().Prefix() + e.Key()
+			if e.Value().Value() != nil && strings.HasPrefix(key, o.prefix) {
+				stats.Keys++
+			}
+			if e.Value().Shard() != nil && (fanout || strings.HasPrefix(key, o.prefix) || strings.HasPrefix(o.prefix, key)) {
+				children = append(children, e.Value().Shard())
+			}
+		}
+		if strings.HasPrefix(s.Value().Prefix(), o.prefix) {
+			stats.Shards++
+			stats.Bytes += len(s.Bytes())
+			stats.Histogram[len(s.Value().Entries())]++
+			stats.MaxDepth = max(stats.MaxDepth, depth)
+		}
+		return children
+	}
+
+	// shards are fetched a level at a time, with at most the configured number
+	// of fetches, and goroutines, in flight
+	level := visit(shard.AsBlock(rshard), 0)
+	for depth := 1; len(level) > 0; depth++ {
+		fetched := make([]shard.BlockView, len(level))
+		g, gctx := errgroup.WithContext(ctx)
+		g.SetLimit(o.concurrency)
+		for i, l := range level {
+			g.Go(func() error {
+				b, err := shards.Get(gctx, l)
+				if err != nil {
+					return fmt.Errorf("getting shard %s: %w", l.String(), err)
+				}
+				fetched[i] = b
+				return nil
+			})
+		}
+		err := g.Wait()
+		if err != nil {
+			return Statistics{}, err
+		}
+
+		level = nil
+		for _, b := range fetched {
+			level = append(level, visit(b, depth)...)
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,195 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("empty pail", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := block.NewMapBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		stats, err := Stats(ctx, bs, rb0.Link())
+		require.NoError(t, err)
+		require.Equal(t, Statistics{
+			Keys:      0,
+			Shards:    1,
+			MaxDepth:  0,
+			Bytes:     len(rb0.Bytes()),
+			Histogram: map[int]int{0: 1},
+		}, stats)
+	})
+
+	t.Run("sharded pail", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := block.NewMapBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := putAll(t, bs, rb0.Link(), []object{
+			{"aaaa", testutil.RandomLink(t)},
+			{"aabb", testutil.RandomLink(t)},
+			{"aa", testutil.RandomLink(t)},
+			{"b", testutil.RandomLink(t)},
+		})
+
+		// blocks for previous roots were removed, so the blockstore contains just
+		// the shards of the pail
+		var size int
+		for b, err := range bs.Entries(ctx) {
+			require.NoError(t, err)
+			size += len(b.Bytes())
+		}
+
+		stats, err := Stats(ctx, bs, r0)
+		require.NoError(t, err)
+		require.Equal(t, Statistics{
+			Keys:      4,
+			Shards:    3,
+			MaxDepth:  2,
+			Bytes:     size,
+			Histogram: map[int]int{1: 1, 2: 2},
+		}, stats)
+	})
+
+	t.Run("many keys", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := block.NewMapBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		var objects []object
+		for i := range 500 {
+			objects = append(objects, object{fmt.Sprintf("key/%d", i), testutil.RandomLink(t)})
+		}
+		r0 := putAll(t, bs, rb0.Link(), objects)
+
+		stats, err := Stats(ctx, bs, r0, WithStatsConcurrency(4))
+		require.NoError(t, err)
+		require.Equal(t, len(objects), stats.Keys)
+		require.Equal(t, len(reachable(t, bs, r0)), stats.Shards)
+
+		var shards int
+		for _, n := range stats.Histogram {
+			shards += n
+		}
+		require.Equal(t, stats.Shards, shards)
+	})
+
+	t.Run("key prefix", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := block.NewMapBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		var objects []object
+		for i := range 20 {
+			objects = append(objects, object{fmt.Sprintf("cats/%d", i), testutil.RandomLink(t)})
+			objects = append(objects, object{fmt.Sprintf("dogs/%d", i), testutil.RandomLink(t)})
+		}
+		r0 := putAll(t, bs, rb0.Link(), objects)
+
+		stats, err := Stats(ctx, bs, r0, WithStatsKeyPrefix("cats/"))
+		require.NoError(t, err)
+		require.Equal(t, 20, stats.Keys)
+
+		all, err := Stats(ctx, bs, r0)
+		require.NoError(t, err)
+		require.Equal(t, 40, all.Keys)
+		require.Less(t, stats.Shards, all.Shards/2)
+		require.Equal(t, all.MaxDepth, stats.MaxDepth)
+	})
+
+	t.Run("wide pail", func(t *testing.T) {
+		bs := block.NewMapBlockstore()
+		var entries []shard.Entry
+		for i := range 200 {
+			key := fmt.Sprintf("%03d", i)
+			child, err := shard.MarshalBlock(shard.New(key, []shard.Entry{
+				shard.NewEntry("x", shard.NewValue(testutil.RandomLink(t), nil)),
+			}))
+			require.NoError(t, err)
+			err = bs.Put(ctx, child)
+			require.NoError(t, err)
+			entries = append(entries, shard.NewEntry(key, shard.NewValue(nil, child.Link())))
+		}
+		rb, err := shard.MarshalBlock(shard.NewRoot(entries))
+		require.NoError(t, err)
+		err = bs.Put(ctx, rb)
+		require.NoError(t, err)
+
+		// the number of goroutines, not only fetches, is limited
+		base := runtime.NumGoroutine()
+		var mutex sync.Mutex
+		var inflight, maxInflight, maxGoroutines int
+		fetcher := fetcherFunc(func(ctx context.Context, link ipld.Link) (block.Block, error) {
+			mutex.Lock()
+			inflight++
+			maxInflight = max(maxInflight, inflight)
+			maxGoroutines = max(maxGoroutines, runtime.NumGoroutine()-base)
+			mutex.Unlock()
+			time.Sleep(time.Millisecond)
+			mutex.Lock()
+			inflight--
+			mutex.Unlock()
+			return bs.Get(ctx, link)
+		})
+
+		stats, err := Stats(ctx, fetcher, rb.Link(), WithStatsConcurrency(4))
+		require.NoError(t, err)
+		require.Equal(t, 200, stats.Keys)
+		require.Equal(t, 201, stats.Shards)
+		require.LessOrEqual(t, maxInflight, 4)
+		require.LessOrEqual(t, maxGoroutines, 4)
+	})
+
+	t.Run("missing shard", func(t *testing.T) {
+		rb0, err := shard.MarshalBlock(shard.NewRoot(nil))
+		require.NoError(t, err)
+
+		bs := block.NewMapBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := putAll(t, bs, rb0.Link(), []object{
+			{"aaaa", testutil.RandomLink(t)},
+			{"aabb", testutil.RandomLink(t)},
+		})
+		rshard, err := shard.NewFetcher(bs).Get(ctx, r0)
+		require.NoError(t, err)
+		err = bs.Del(ctx, rshard.Value().Entries()[0].Value().Shard())
+		require.NoError(t, err)
+
+		_, err = Stats(ctx, bs, r0)
+		require.ErrorIs(t, err, block.ErrNotFound)
+	})
+}
+
+type fetcherFunc func(ctx context.Context, link ipld.Link) (block.Block, error)
+
+func (f fetcherFunc) Get(ctx context.Context, link ipld.Link) (block.Block, error) {
+	return f(ctx, link)
+}
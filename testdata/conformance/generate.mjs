@@ -0,0 +1,145 @@
+// Generates the conformance test vectors in this directory using the
+// JavaScript pail implementation, so go-pail can be checked against it.
+//
+// Usage:
+//
+//	npm install @web3-storage/pail @ipld/dag-cbor multiformats
+//	node generate.mjs
+//
+// or make conformance-fixtures from the repository root. Commit the generated
+// pail.json and crdt.json, then run the tests with make conformance. Once the
+// fixtures are committed, remove the conformance build tag from
+// conformance_test.go and crdt/conformance_test.go, so the tests run with
+// go test ./... like the rest of the suite.
+import fs from 'node:fs'
+import { createRequire } from 'node:module'
+import * as dagCBOR from '@ipld/dag-cbor'
+import { CID } from 'multiformats/cid'
+import * as raw from 'multiformats/codecs/raw'
+import { sha256 } from 'multiformats/hashes/sha2'
+import { ShardBlock, put, del } from '@web3-storage/pail'
+import { MemoryBlockstore } from '@web3-storage/pail/block'
+import * as CRDT from '@web3-storage/pail/crdt'
+
+const require = createRequire(import.meta.url)
+const { version } = require('@web3-storage/pail/package.json')
+const generator = `@web3-storage/pail@${version}`
+
+/** Deterministic value CID for the passed number. */
+const value = async n =>
+  CID.create(1, raw.code, await sha256.digest(new TextEncoder().encode(`value-${n}`))).toString()
+
+const putOp = async (key, n) => ({ type: 'put', key, value: await value(n) })
+const delOp = key => ({ type: 'del', key })
+
+const range = n => [...Array(n).keys()]
+
+const vectors = [
+  {
+    name: 'single put',
+    operations: [
+      { type: 'put', key: 'room-guardian.jpg', value: 'bafkreigh2akiscaildcqabsyg3dfr6chu3fgpregiymsck7e7aqa4s52zy' }
+    ]
+  },
+  {
+    name: 'shared prefixes',
+    operations: [
+      await putOp('aaaa', 0),
+      await putOp('aabb', 1),
+      await putOp('aacc', 2),
+      await putOp('ab', 3),
+      await putOp('b', 4)
+    ]
+  },
+  {
+    name: 'shard and value',
+    operations: [
+      await putOp('a', 0),
+      await putOp('ab', 1),
+      await putOp('abc', 2),
+      await putOp('abcd', 3)
+    ]
+  },
+  {
+    name: 'overwrite',
+    operations: [
+      await putOp('key', 0),
+      await putOp('key', 1),
+      await putOp('keys', 2),
+      await putOp('key', 3)
+    ]
+  },
+  {
+    name: 'delete',
+    operations: [
+      await putOp('aaaa', 0),
+      await putOp('aabb', 1),
+      await putOp('aa', 2),
+      await putOp('b', 3),
+      delOp('aabb'),
+      delOp('aa'),
+      delOp('aaaa')
+    ]
+  },
+  {
+    name: 'many keys',
+    operations: await Promise.all(range(50).map(i => putOp(`data/file-${String(i).padStart(2, '0')}.txt`, i)))
+  }
+]
+
+/** Collects the CIDs and bytes of all shards reachable from the root. */
+const collectShards = async (blocks, root) => {
+  const shards = []
+  const collect = async cid => {
+    const { bytes } = await blocks.get(cid)
+    shards.push({ cid: cid.toString(), bytes: Buffer.from(bytes).toString('hex') })
+    for (const [, v] of dagCBOR.decode(bytes).entries) {
+      if (Array.isArray(v)) await collect(v[0])
+    }
+  }
+  await collect(root)
+  return shards.sort((a, b) => a.cid < b.cid ? -1 : a.cid > b.cid ? 1 : 0)
+}
+
+const pailVectors = []
+for (const v of vectors) {
+  const rootblk = await ShardBlock.create()
+  const blocks = new MemoryBlockstore()
+  blocks.putSync(rootblk.cid, rootblk.bytes)
+
+  let root = rootblk.cid
+  const roots = []
+  for (const op of v.operations) {
+    const res = op.type === 'put'
+      ? await put(blocks, root, op.key, CID.parse(op.value))
+      : await del(blocks, root, op.key)
+    for (const b of res.additions) blocks.putSync(b.cid, b.bytes)
+    for (const b of res.removals) blocks.deleteSync(b.cid)
+    root = res.root
+    roots.push(root.toString())
+  }
+  pailVectors.push({ ...v, roots, shards: await collectShards(blocks, root) })
+}
+
+const crdtVectors = []
+for (const v of vectors) {
+  const blocks = new MemoryBlockstore()
+  let head = []
+  const results = []
+  for (const op of v.operations) {
+    const res = op.type === 'put'
+      ? await CRDT.put(blocks, head, op.key, CID.parse(op.value))
+      : await CRDT.del(blocks, head, op.key)
+    blocks.putSync(res.event.cid, res.event.bytes)
+    for (const b of res.additions) blocks.putSync(b.cid, b.bytes)
+    head = res.head
+    results.push({ root: res.root.toString(), head: head.map(String), event: res.event.cid.toString() })
+  }
+  crdtVectors.push({ ...v, results })
+}
+
+const write = (file, vectors) =>
+  fs.writeFileSync(new URL(file, import.meta.url), JSON.stringify({ generator, vectors }, null, 2) + '\n')
+
+write('pail.json', pailVectors)
+write('crdt.json', crdtVectors)
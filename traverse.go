@@ -11,7 +11,22 @@ import (
 // Traverse from the passed shard block to the target shard block using the
 // passed key. All traversed shards are returned, starting with the passed shard
 // and ending with the target.
+//
+// Fanout shards are traversed to the part the key belongs to, so the target is
+// never a fanout shard.
 func traverse(ctx context.Context, shards *shard.Fetcher, shardBlock shard.BlockView, key string) ([]shard.BlockView, error) {
+	if shard.IsFanout(shardBlock.Value()) {
+		e := shardBlock.Value().Entries()[route(shardBlock.Value().Entries(), key)]
+		s, err := shards.Get(ctx, e.Value().Shard())
+		if err != nil {
+			return nil, fmt.Errorf("getting shard %s: %w", e.Value().Shard().String(), err)
+		}
+		path, err := traverse(ctx, shards, s, key)
+		if err != nil {
+			return nil, err
+		}
+		return append([]shard.BlockView{shardBlock}, path...), nil
+	}
 	for _, e := range shardBlock.Value().Entries() {
 		k := e.Key()
 		v := e.Value()
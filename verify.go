@@ -0,0 +1,234 @@
+package pail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/shard"
+)
+
+// Problems reported by [Verify]. Problems with the encoding or entries of a
+// shard are reported using the errors of strict decoding, e.g.
+// [shard.ErrUnsortedEntries].
+var (
+	ErrHashMismatch   = errors.New("block data does not match CID")
+	ErrPrefixMismatch = errors.New("shard prefix does not match parent prefix and entry key")
+	ErrEmptyShard     = errors.New("shard has no entries")
+	ErrDuplicateShard = errors.New("shard is linked to more than once")
+	ErrRangeMismatch  = errors.New("shard has keys outside the range of its fanout entry")
+)
+
+// Problem is an integrity problem with a shard found by [Verify].
+type Problem struct {
+	// Path is the links of the shards from the root shard to the shard with the
+	// problem.
+	Path []ipld.Link
+	// Prefix is the key prefix of the shard, as expected from the path.
+	Prefix string
+	// Err describes the problem.
+	Err error
+}
+
+func (p Problem) Error() string {
+	var path []string
+	for _, l := range p.Path {
+		path = append(path, l.String())
+	}
+	return fmt.Sprintf("shard %q (%s): %s", p.Prefix, strings.Join(path, " > "), p.Err)
+}
+
+func (p Problem) Unwrap() error {
+	return p.Err
+}
+
+// Verify walks every shard of the pail and checks its integrity. It checks that:
+//
+//   - each block hashes to its CID.
+//   - each shard can be decoded in strict mode (see [shard.WithStrict]), so its
+//     entries are sorted and unique, only the root shard has an entry with an
+//     empty key, no key exceeds the maximum key size of the root shard,
+//     inline values are allowed by the root shard and every shard is of the
+//     version of the root shard.
+//   - the prefix of each shard is the prefix of its parent plus the key of the
+//     entry that links to it, or the prefix of its parent if it is a part of a
+//     fanout shard (see [shard.WithFanout]).
+//   - the keys of each part are within the range of the fanout entry that
+//     links to it, and fanout entry keys are single characters.
+//   - no shard is empty, except the root shard.
+//   - no shard is linked to more than once.
+//
+// Every problem found is returned, along with the path to the shard it was
+// found in. Shards that cannot be fetched, do not match their CID, cannot be
+// decoded or were already visited are reported as problems and not walked any
+// further. An error is returned if the root shard cannot be fetched or the
+// context is canceled.
+func Verify(ctx context.Context, blocks block.Fetcher, root ipld.Link) ([]Problem, error) {
+	b, err := blocks.Get(ctx, root)
+	if err != nil {
+		return nil, fmt.Errorf("getting root: %w", err)
+	}
+
+	var problems []Problem
+	report := func(path []ipld.Link, prefix string, err error) {
+		problems = append(problems, Problem{Path: path, Prefix: prefix, Err: err})
+	}
+
+	path := []ipld.Link{root}
+	err = checkHash(b)
+	if err != nil {
+		report(path, "", err)
+		return problems, nil
+	}
+	rshard, err := shard.UnmarshalRoot(b.Bytes(), shard.WithStrict())
+	if err != nil {
+		report(path, "", fmt.Errorf("decoding root shard: %w", err))
+		return problems, nil
+	}
+	err = validateRoot(rshard)
+	if err != nil {
+		report(path, "", err)
+	}
+	if rshard.Prefix() != "" {
+		report(path, "", fmt.Errorf("%w: root shard has prefix %q", ErrPrefixMismatch, rshard.Prefix()))
+	}
+
+	opts := []shard.UnmarshalOption{
+		shard.WithStrict(),
+		shard.WithPailMaxKeySize(rshard.MaxKeySize()),
+		shard.WithPailMaxInlineSize(rshard.MaxInlineSize()),
+		shard.WithPailMaxShardSize(rshard.MaxShardSize()),
+		shard.WithPailVersion(rshard.Version()),
+	}
+	visited := map[string]struct{}{root.String(): {}}
+
+	// visit walks the shards linked from the passed shard, whose keys are
+	// within the passed range (see [keyRange]).
+	var visit func(path []ipld.Link, prefix string, s shard.Shard, r keyRange) error
+	visit = func(path []ipld.Link, prefix string, s shard.Shard, r keyRange) error {
+		fanout := shard.IsFanout(s)
+		for i, e := range s.Entries() {
+			if e.Value().Shard() == nil {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			link := e.Value().Shard()
+			key := prefix + e.Key()
+			cr := keyRange{}
+			if fanout {
+				// parts have the same prefix as the fanout shard
+				key = prefix
+				cr = r.part(s.Entries(), i)
+			}
+			cpath := append(path[:len(path):len(path)], link)
+
+			if fanout && i > 0 && len(splitKey(rshard, e.Key())) != 1 {
+				report(path, prefix, fmt.Errorf("%w: entry key %q is not a single character", shard.ErrInvalidFanout, e.Key()))
+			}
+
+			if _, ok := visited[link.String()]; ok {
+				report(cpath, key, ErrDuplicateShard)
+				continue
+			}
+			visited[link.String()] = struct{}{}
+
+			b, err := blocks.Get(ctx, link)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				report(cpath, key, fmt.Errorf("getting shard: %w", err))
+				continue
+			}
+			err = checkHash(b)
+			if err != nil {
+				report(cpath, key, err)
+				continue
+			}
+			c, err := shard.Unmarshal(b.Bytes(), opts...)
+			if err != nil {
+				report(cpath, key, fmt.Errorf("decoding shard: %w", err))
+				continue
+			}
+			if c.Prefix() != key {
+				report(cpath, key, fmt.Errorf("%w: shard has prefix %q", ErrPrefixMismatch, c.Prefix()))
+			}
+			if len(c.Entries()) == 0 {
+				report(cpath, key, ErrEmptyShard)
+			}
+			if k, ok := cr.outside(c); ok {
+				report(cpath, key, fmt.Errorf("%w: %q", ErrRangeMismatch, key+k))
+			}
+			err = visit(cpath, key, c, cr)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err = visit(path, "", rshard, keyRange{})
+	if err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// keyRange is the range of keys, relative to the shard prefix, of a part of a
+// fanout shard. Keys are at least lower, and less than upper if bounded. The
+// zero value is the unrestricted range of a shard that is not a part.
+type keyRange struct {
+	lower   string
+	upper   string
+	bounded bool
+}
+
+// part returns the range of the part linked by the entry at index i of a
+// fanout shard with this range.
+func (r keyRange) part(entries []shard.Entry, i int) keyRange {
+	lower, upper, last := partBounds(entries, i)
+	pr := r
+	if i > 0 {
+		pr.lower = lower
+	}
+	if !last {
+		pr.upper, pr.bounded = upper, true
+	}
+	return pr
+}
+
+// outside returns the key of an entry of the passed shard that is outside the
+// range, ignoring the empty key of the first entry of a fanout shard.
+func (r keyRange) outside(s shard.Shard) (string, bool) {
+	for i, e := range s.Entries() {
+		if i == 0 && shard.IsFanout(s) {
+			continue
+		}
+		if e.Key() < r.lower || (r.bounded && e.Key() >= r.upper) {
+			return e.Key(), true
+		}
+	}
+	return "", false
+}
+
+// checkHash checks that the bytes of the block hash to its CID.
+func checkHash(b block.Block) error {
+	cl, ok := b.Link().(cidlink.Link)
+	if !ok {
+		return fmt.Errorf("unsupported link type: %T", b.Link())
+	}
+	c, err := cl.Cid.Prefix().Sum(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("hashing block: %w", err)
+	}
+	if !c.Equals(cl.Cid) {
+		return fmt.Errorf("%w: %s", ErrHashMismatch, c.String())
+	}
+	return nil
+}
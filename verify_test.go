@@ -0,0 +1,276 @@
+package pail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/storacha/go-pail/block"
+	"github.com/storacha/go-pail/internal/testutil"
+	"github.com/storacha/go-pail/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("valid pails", func(t *testing.T) {
+		var objects []object
+		for i := range 200 {
+			objects = append(objects, object{fmt.Sprintf("dir%d/file%03d.txt", i%5, i), testutil.RandomLink(t)})
+		}
+		options := [][]shard.RootOption{
+			nil,
+			{shard.WithVersion(shard.Version3)},
+			{shard.WithKeyChars(shard.KeyCharsBinary)},
+			{shard.WithMaxShardSize(2048)},
+			{shard.WithMaxShardSize(256)},
+		}
+		for _, opts := range options {
+			rb0, err := New(opts...)
+			require.NoError(t, err)
+
+			bs := testutil.NewBlockstore()
+			err = bs.Put(ctx, rb0)
+			require.NoError(t, err)
+
+			r0 := putAll(t, bs, rb0.Link(), objects)
+			problems, err := Verify(ctx, bs, r0)
+			require.NoError(t, err)
+			require.Empty(t, problems)
+		}
+	})
+
+	t.Run("block does not match CID", func(t *testing.T) {
+		rb0, err := New()
+		require.NoError(t, err)
+
+		bs := testutil.NewBlockstore()
+		err = bs.Put(ctx, rb0)
+		require.NoError(t, err)
+
+		r0 := putAll(t, bs, rb0.Link(), []object{
+			{"aaaa", testutil.RandomLink(t)},
+			{"aabb", testutil.RandomLink(t)},
+			{"bbbb", testutil.RandomLink(t)},
+			{"bbcc", testutil.RandomLink(t)},
+		})
+		rshard, err := shard.NewFetcher(bs).GetRoot(ctx, r0)
+		require.NoError(t, err)
+		a := rshard.Value().Entries()[0].Value().Shard()
+		b := rshard.Value().Entries()[1].Value().Shard()
+
+		// replace the data of shard "a" with the data of shard "b"
+		bb, err := bs.Get(ctx, b)
+		require.NoError(t, err)
+		err = bs.Put(ctx, block.New(a, bb.Bytes()))
+		require.NoError(t, err)
+
+		problems, err := Verify(ctx, bs, r0)
+		require.NoError(t, err)
+		// the data of shard "a" is not walked any further
+		require.Len(t, problems, 1)
+		require.Equal(t, "a", problems[0].Prefix)
+		require.Equal(t, []string{r0.String(), a.String()}, linkStrings(problems[0].Path))
+		require.ErrorIs(t, problems[0], ErrHashMismatch)
+	})
+
+	t.Run("shard linked more than once", func(t *testing.T) {
+		bs := testutil.NewBlockstore()
+		put := func(s shard.Shard) shard.BlockView {
+			b, err := shard.MarshalBlock(s)
+			require.NoError(t, err)
+			err = bs.Put(ctx, b)
+			require.NoError(t, err)
+			return b
+		}
+		v := testutil.RandomLink(t)
+
+		// each shard links to the next twice, which would be walked 2^n times
+		child := put(shard.New("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", []shard.Entry{
+			shard.NewEntry("a", shard.NewValue(v, nil)),
+		}))
+		for i := 29; i > 0; i-- {
+			child = put(shard.New(strings.Repeat("a", i), []shard.Entry{
+				shard.NewEntry("a", shard.NewValue(nil, child.Link())),
+				shard.NewEntry("b", shard.NewValue(nil, child.Link())),
+			}))
+		}
+		rb := put(shard.NewRoot([]shard.Entry{
+			shard.NewEntry("a", shard.NewValue(nil, child.Link())),
+		}))
+
+		problems, err := Verify(ctx, bs, rb.Link())
+		require.NoError(t, err)
+		require.Len(t, problems, 29)
+		for _, p := range problems {
+			require.ErrorIs(t, p, ErrDuplicateShard)
+		}
+	})
+
+	t.Run("part keys outside fanout range", func(t *testing.T) {
+		bs := testutil.NewBlockstore()
+		put := func(s shard.Shard) shard.BlockView {
+			b, err := shard.MarshalBlock(s)
+			require.NoError(t, err)
+			err = bs.Put(ctx, b)
+			require.NoError(t, err)
+			return b
+		}
+		v := testutil.RandomLink(t)
+
+		// "d" belongs to the second part
+		p0 := put(shard.New("", []shard.Entry{
+			shard.NewEntry("a", shard.NewValue(v, nil)),
+			shard.NewEntry("d", shard.NewValue(v, nil)),
+		}))
+		p1 := put(shard.New("", []shard.Entry{
+			shard.NewEntry("c", shard.NewValue(v, nil)),
+		}))
+		rb := put(shard.NewRoot([]shard.Entry{
+			shard.NewEntry("", shard.NewValue(nil, p0.Link())),
+			shard.NewEntry("c", shard.NewValue(nil, p1.Link())),
+		}, shard.WithMaxShardSize(1024), shard.WithRootFanout()))
+
+		problems, err := Verify(ctx, bs, rb.Link())
+		require.NoError(t, err)
+		require.Len(t, problems, 1)
+		require.Equal(t, []string{rb.Link().String(), p0.Link().String()}, linkStrings(problems[0].Path))
+		require.ErrorIs(t, problems[0], ErrRangeMismatch)
+	})
+
+	t.Run("cyclic store", func(t *testing.T) {
+		rb, err := shard.MarshalBlock(shard.NewRoot([]shard.Entry{
+			shard.NewEntry("a", shard.NewValue(nil, testutil.RandomLink(t))),
+		}))
+		require.NoError(t, err)
+
+		// returns the root shard for every link, so each shard links to another
+		blocks := fetcherFunc(func(ctx context.Context, link ipld.Link) (block.Block, error) {
+			return block.New(link, rb.Bytes()), nil
+		})
+		problems, err := Verify(ctx, blocks, rb.Link())
+		require.NoError(t, err)
+		require.Len(t, problems, 1)
+		require.ErrorIs(t, problems[0], ErrHashMismatch)
+	})
+
+	t.Run("inline values not allowed", func(t *testing.T) {
+		bs := testutil.NewBlockstore()
+		put := func(s shard.Shard) shard.BlockView {
+			b, err := shard.MarshalBlock(s)
+			require.NoError(t, err)
+			err = bs.Put(ctx, b)
+			require.NoError(t, err)
+			return b
+		}
+		v := inlineLink(t, basicnode.NewString("small"))
+
+		child := put(shard.New("a", []shard.Entry{
+			shard.NewEntry("a", shard.NewValue(v, nil)),
+		}))
+		rb := put(shard.NewRoot([]shard.Entry{
+			shard.NewEntry("a", shard.NewValue(nil, child.Link())),
+		}))
+
+		problems, err := Verify(ctx, bs, rb.Link())
+		require.NoError(t, err)
+		require.Len(t, problems, 1)
+		require.Equal(t, "a", problems[0].Prefix)
+		require.ErrorIs(t, problems[0], shard.ErrInlineNotAllowed)
+	})
+
+	t.Run("shard version differs from pail version", func(t *testing.T) {
+		bs := testutil.NewBlockstore()
+		put := func(s shard.Shard) shard.BlockView {
+			b, err := shard.MarshalBlock(s)
+			require.NoError(t, err)
+			err = bs.Put(ctx, b)
+			require.NoError(t, err)
+			return b
+		}
+
+		child := put(shard.New("a", []shard.Entry{
+			shard.NewEntry("a", shard.NewValue(testutil.RandomLink(t), nil)),
+		}))
+		rb := put(shard.NewRoot([]shard.Entry{
+			shard.NewEntry("a", shard.NewValue(nil, child.Link())),
+		}, shard.WithVersion(shard.Version3)))
+
+		problems, err := Verify(ctx, bs, rb.Link())
+		require.NoError(t, err)
+		require.Len(t, problems, 1)
+		require.Equal(t, "a", problems[0].Prefix)
+		require.ErrorIs(t, problems[0], shard.ErrVersionMismatch)
+
+		proof, err := Prove(ctx, bs, rb.Link(), "aa")
+		require.NoError(t, err)
+		_, err = VerifyProof(rb.Link(), "aa", proof)
+		require.ErrorIs(t, err, shard.ErrVersionMismatch)
+	})
+
+	t.Run("reports a problem for each shard", func(t *testing.T) {
+		bs := testutil.NewBlockstore()
+		put := func(s shard.Shard) shard.BlockView {
+			b, err := shard.MarshalBlock(s)
+			require.NoError(t, err)
+			err = bs.Put(ctx, b)
+			require.NoError(t, err)
+			return b
+		}
+		v := testutil.RandomLink(t)
+
+		empty := put(shard.New("a", nil))
+		unsorted := put(shard.New("b", []shard.Entry{
+			shard.NewEntry("", shard.NewValue(v, nil)),
+			shard.NewEntry("b", shard.NewValue(v, nil)),
+			shard.NewEntry("a", shard.NewValue(v, nil)),
+			shard.NewEntry("a", shard.NewValue(v, nil)),
+		}))
+		missing, err := shard.MarshalBlock(shard.New("c", []shard.Entry{
+			shard.NewEntry("c", shard.NewValue(v, nil)),
+		}))
+		require.NoError(t, err)
+		long := put(shard.New("d", []shard.Entry{
+			shard.NewEntry("ddddd", shard.NewValue(v, nil)),
+		}))
+
+		rb := put(shard.NewRoot([]shard.Entry{
+			shard.NewEntry("a", shard.NewValue(nil, empty.Link())),
+			shard.NewEntry("b", shard.NewValue(nil, unsorted.Link())),
+			shard.NewEntry("c", shard.NewValue(nil, missing.Link())),
+			shard.NewEntry("d", shard.NewValue(nil, long.Link())),
+		}, shard.WithMaxKeySize(4)))
+
+		problems, err := Verify(ctx, bs, rb.Link())
+		require.NoError(t, err)
+		require.Len(t, problems, 4)
+
+		require.Equal(t, "a", problems[0].Prefix)
+		require.ErrorIs(t, problems[0], ErrEmptyShard)
+		// strict decoding reports the first problem with the entries
+		require.Equal(t, "b", problems[1].Prefix)
+		require.ErrorIs(t, problems[1], shard.ErrEmptyKey)
+		require.Equal(t, "c", problems[2].Prefix)
+		require.ErrorContains(t, problems[2], "getting shard")
+		require.Equal(t, "d", problems[3].Prefix)
+		require.ErrorIs(t, problems[3], shard.ErrKeyTooLarge)
+		require.Equal(t, []string{rb.Link().String(), long.Link().String()}, linkStrings(problems[3].Path))
+	})
+
+	t.Run("root not found", func(t *testing.T) {
+		_, err := Verify(ctx, testutil.NewBlockstore(), testutil.RandomLink(t))
+		require.Error(t, err)
+	})
+}
+
+func linkStrings(links []ipld.Link) []string {
+	var strs []string
+	for _, l := range links {
+		strs = append(strs, l.String())
+	}
+	return strs
+}